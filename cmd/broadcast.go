@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"tg_bot/logger"
+	"tg_bot/pkg/bot"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+)
+
+func InitBroadcastCommand() *cobra.Command {
+	var message string
+	var broadcastCmd = &cobra.Command{
+		Use:   "broadcast",
+		Short: "Send a message to every user",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).Validate)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			botApp, err := bot.NewBot(cfg, dao.NewUsers(dbConn), dao.NewTasks(dbConn), dao.NewSnapshots(dbConn), dao.NewNotes(dbConn), dao.NewShares(dbConn), dao.NewTeams(dbConn), dao.NewLinks(dbConn), dao.NewTokens(dbConn), dao.NewFeeds(dbConn))
+			if err != nil {
+				logger.Get().Error("Bot app could not be created", zap.Error(err))
+				os.Exit(1)
+			}
+
+			sent, failed, err := botApp.Broadcast(message)
+			if err != nil {
+				logger.Get().Error("Broadcast failed", zap.Int("sent", sent), zap.Int("failed", failed), zap.Error(err))
+				os.Exit(1)
+			}
+			logger.Get().Info("Broadcast finished", zap.Int("sent", sent), zap.Int("failed", failed))
+		},
+	}
+	broadcastCmd.Flags().StringVar(&message, "message", "", "text of the message")
+	broadcastCmd.MarkFlagRequired("message")
+
+	config.BindFlags(broadcastCmd.Flags())
+
+	return broadcastCmd
+}
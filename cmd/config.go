@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"database/sql"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+)
+
+// loadConfig loads the config for a command and exits on any problem. The
+// validate function decides which parts of the config the command needs.
+func loadConfig(cmd *cobra.Command, validate func(cfg *config.Config) error) *config.Config {
+	cfg, err := config.Load(cmd.Flags())
+	if err != nil {
+		logger.Get().Error("Could not load config", zap.Error(err))
+		os.Exit(1)
+	}
+
+	err = validate(cfg)
+	if err != nil {
+		logger.Get().Error("Invalid config:\n" + err.Error())
+		os.Exit(1)
+	}
+
+	err = logger.SetLevel(cfg.Log.Level)
+	if err != nil {
+		logger.Get().Error("Invalid log level", zap.Error(err))
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+func openDB(cfg *config.DBConfig) (*sql.DB, error) {
+	dbConn, err := sql.Open("mysql", cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	dbConn.SetMaxOpenConns(cfg.MaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.MaxIdleConns)
+	dbConn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	return dbConn, nil
+}
+
+// connectDB opens the database for admin commands and exits on failure.
+func connectDB(cfg *config.DBConfig) *sql.DB {
+	dbConn, err := openDB(cfg)
+	if err != nil {
+		logger.Get().Error("DB connection failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	return dbConn
+}
+
+// getUser looks a user up by Telegram ID and exits if there is none.
+func getUser(usersDao dao.Users, externalId string) *models.User {
+	user, err := usersDao.GetUserByExternalId(externalId)
+	if err != nil {
+		logger.Get().Error("Could not get user", zap.String("external_id", externalId), zap.Error(err))
+		os.Exit(1)
+	}
+
+	return user
+}
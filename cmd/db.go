@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"text/tabwriter"
+	"tg_bot/db"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+)
+
+func InitDBCommand() *cobra.Command {
+	var dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Inspect the database",
+	}
+
+	var statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show user and task counts and the schema version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).ValidateDB)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			usersCount, err := dao.NewUsers(dbConn).CountUsers()
+			if err != nil {
+				logger.Get().Error("Could not count users", zap.Error(err))
+				os.Exit(1)
+			}
+			tasksCounts, err := dao.NewTasks(dbConn).CountTasksByStatus()
+			if err != nil {
+				logger.Get().Error("Could not count tasks", zap.Error(err))
+				os.Exit(1)
+			}
+			status, err := db.NewMigrator(cfg.DB.DSN()).Status()
+			if err != nil {
+				logger.Get().Error("Could not read migration status", zap.Error(err))
+				os.Exit(1)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Schema version:\t%d", status.Version)
+			if status.Dirty {
+				fmt.Fprint(w, " (dirty)")
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "Users:\t%d\n", usersCount)
+			total := 0
+			for _, s := range models.TaskStatuses {
+				fmt.Fprintf(w, "Tasks %s:\t%d\n", s, tasksCounts[s])
+				total += tasksCounts[s]
+			}
+			fmt.Fprintf(w, "Tasks total:\t%d\n", total)
+			w.Flush()
+		},
+	}
+
+	dbCmd.AddCommand(statsCmd)
+
+	config.BindFlags(dbCmd.PersistentFlags())
+
+	return dbCmd
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"tg_bot/db"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+)
+
+func InitMigrateCommand() *cobra.Command {
+	var migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema",
+	}
+
+	var upCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runMigration(newMigrator(cmd).Migrate())
+		},
+	}
+
+	var all bool
+	var downCmd = &cobra.Command{
+		Use:   "down [N]",
+		Short: "Roll back the last N migrations, one by default",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			steps := 1
+			if all {
+				steps = 0
+			} else if len(args) > 0 {
+				steps = parseVersionArg(args[0])
+				if steps < 1 {
+					logger.Get().Error("Number of migrations to roll back must be positive")
+					os.Exit(1)
+				}
+			}
+
+			runMigration(newMigrator(cmd).Down(steps))
+		},
+	}
+	downCmd.Flags().BoolVar(&all, "all", false, "roll back all migrations")
+
+	var statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := newMigrator(cmd).Status()
+			if err != nil {
+				logger.Get().Error("Could not read migration status", zap.Error(err))
+				os.Exit(1)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Version: %d", status.Version)
+			if status.Dirty {
+				fmt.Fprint(out, " (dirty, fix the schema and run `migrate force`)")
+			}
+			fmt.Fprintln(out)
+			fmt.Fprintln(out)
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+			for _, m := range status.Migrations {
+				state := "pending"
+				if m.Applied {
+					state = "applied"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, state)
+			}
+			w.Flush()
+		},
+	}
+
+	var gotoCmd = &cobra.Command{
+		Use:   "goto VERSION",
+		Short: "Migrate up or down to the given version",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			version := parseVersionArg(args[0])
+			if version < 0 {
+				logger.Get().Error("Version must not be negative")
+				os.Exit(1)
+			}
+
+			runMigration(newMigrator(cmd).Goto(uint(version)))
+		},
+	}
+
+	var forceCmd = &cobra.Command{
+		Use:   "force VERSION",
+		Short: "Set the version without running migrations, -1 for none",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runMigration(newMigrator(cmd).Force(parseVersionArg(args[0])))
+		},
+	}
+
+	migrateCmd.AddCommand(upCmd, downCmd, statusCmd, gotoCmd, forceCmd)
+
+	config.BindFlags(migrateCmd.PersistentFlags())
+
+	return migrateCmd
+}
+
+func newMigrator(cmd *cobra.Command) *db.Migrator {
+	cfg := loadConfig(cmd, (*config.Config).ValidateDB)
+
+	return db.NewMigrator(cfg.DB.DSN())
+}
+
+func runMigration(err error) {
+	if err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			logger.Get().Info("DB already migrated")
+			return
+		}
+		logger.Get().Error("DB migration failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Get().Info("DB migrated")
+}
+
+func parseVersionArg(arg string) int {
+	version, err := strconv.Atoi(arg)
+	if err != nil {
+		logger.Get().Error("Invalid number", zap.String("value", arg))
+		os.Exit(1)
+	}
+
+	return version
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"tg_bot/logger"
+	"tg_bot/pkg/bot"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+)
+
+func InitRemindersCommand() *cobra.Command {
+	var remindersCmd = &cobra.Command{
+		Use:   "reminders",
+		Short: "Manage reminders",
+	}
+
+	var externalId string
+	var sendNowCmd = &cobra.Command{
+		Use:   "send-now",
+		Short: "Send reminders right away, to everyone or to one user",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).Validate)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			usersDao := dao.NewUsers(dbConn)
+			botApp, err := bot.NewBot(cfg, usersDao, dao.NewTasks(dbConn), dao.NewSnapshots(dbConn), dao.NewNotes(dbConn), dao.NewShares(dbConn), dao.NewTeams(dbConn), dao.NewLinks(dbConn), dao.NewTokens(dbConn), dao.NewFeeds(dbConn))
+			if err != nil {
+				logger.Get().Error("Bot app could not be created", zap.Error(err))
+				os.Exit(1)
+			}
+
+			if externalId == "" {
+				err = botApp.SendReminders()
+			} else {
+				err = botApp.SendUserReminder(getUser(usersDao, externalId))
+			}
+			if err != nil {
+				logger.Get().Error("Could not send reminders", zap.Error(err))
+				os.Exit(1)
+			}
+			logger.Get().Info("Reminders sent")
+		},
+	}
+	sendNowCmd.Flags().StringVar(&externalId, "user", "", "Telegram ID of the user, all users if empty")
+
+	remindersCmd.AddCommand(sendNowCmd)
+
+	config.BindFlags(remindersCmd.PersistentFlags())
+
+	return remindersCmd
+}
@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"github.com/go-co-op/gocron"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/spf13/cobra"
@@ -11,46 +10,31 @@ import (
 	"os/signal"
 	"tg_bot/db"
 	"tg_bot/logger"
+	"tg_bot/pkg/api"
 	"tg_bot/pkg/bot"
+	"tg_bot/pkg/config"
 	"tg_bot/pkg/dao"
+	"tg_bot/pkg/linkcheck"
+	"tg_bot/pkg/listfeed"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/reminders"
+	"tg_bot/pkg/retention"
+	"tg_bot/pkg/server"
 	"time"
 )
 
-func InitRunCommand() *cobra.Command {
-	var apiKey string
+// linkCheckBatch is the number of links checked every 10 minutes.
+const linkCheckBatch = 100
 
+func InitRunCommand() *cobra.Command {
 	var runCmd = &cobra.Command{
 		Use:   "run",
 		Short: "Run the bot",
 		Run: func(cmd *cobra.Command, args []string) {
-			apiKey, _ = os.LookupEnv("TG_BOT_API_KEY")
-			if apiKey == "" {
-				logger.Get().Error("Please set the TG_BOT_API_KEY environment variable")
-				os.Exit(1)
-			}
-			dbUser, _ := os.LookupEnv("TG_BOT_DB_USER")
-			if dbUser == "" {
-				logger.Get().Error("Please set the TG_BOT_DB_USER environment variable")
-				os.Exit(1)
-			}
-			dbPass, _ := os.LookupEnv("TG_BOT_DB_PASSWORD")
-			if dbPass == "" {
-				logger.Get().Error("Please set the TG_BOT_DB_PASSWORD environment variable")
-				os.Exit(1)
-			}
-			dbHost, _ := os.LookupEnv("TG_BOT_DB_HOST")
-			if dbHost == "" {
-				logger.Get().Error("Please set the TG_BOT_DB_HOST environment variable")
-				os.Exit(1)
-			}
-			dbPort, _ := os.LookupEnv("TG_BOT_DB_PORT")
-			if dbPort == "" {
-				logger.Get().Error("Please set the TG_BOT_DB_PORT environment variable")
-				os.Exit(1)
-			}
+			cfg := loadConfig(cmd, (*config.Config).Validate)
 
-			dbConnUrl := fmt.Sprintf("%s:%s@tcp(%s:%s)/read_that_bot?parseTime=true", dbUser, dbPass, dbHost, dbPort)
-			dbConn, err := sql.Open("mysql", dbConnUrl)
+			dbConn, err := openDB(&cfg.DB)
 			if err != nil {
 				logger.Get().Error("DB connection failed", zap.Error(err))
 				os.Exit(1)
@@ -60,47 +44,204 @@ func InitRunCommand() *cobra.Command {
 			usersDao := dao.NewUsers(dbConn)
 			tasksDao := dao.NewTasks(dbConn)
 
-			botApp, err := bot.NewBot(apiKey, usersDao, tasksDao)
+			linksDao := dao.NewLinks(dbConn)
+			tokensDao := dao.NewTokens(dbConn)
+
+			botApp, err := bot.NewBot(cfg, usersDao, tasksDao, dao.NewSnapshots(dbConn), dao.NewNotes(dbConn), dao.NewShares(dbConn), dao.NewTeams(dbConn), linksDao, tokensDao, dao.NewFeeds(dbConn))
 			if err != nil {
 				logger.Get().Error("Bot app could not be created", zap.Error(err))
 				os.Exit(1)
 			}
 
-			migrator := db.NewMigrator(dbConnUrl)
-			err = migrator.Migrate()
-			if err != nil {
-				if err == migrate.ErrNoChange {
-					logger.Get().Info("DB already migrated")
-				} else {
-					logger.Get().Error("DB migration failed", zap.Error(err))
-					os.Exit(1)
+			if cfg.DB.AutoMigrate {
+				migrator := db.NewMigrator(cfg.DB.DSN())
+				err = migrator.Migrate()
+				if err != nil {
+					if err == migrate.ErrNoChange {
+						logger.Get().Info("DB already migrated")
+					} else {
+						logger.Get().Error("DB migration failed", zap.Error(err))
+						os.Exit(1)
+					}
 				}
+			} else {
+				logger.Get().Info("Automatic DB migration is disabled")
 			}
 
 			s := gocron.NewScheduler(time.UTC)
-			s.Every(1).Day().At("17:00").Do(func() {
-				logger.Get().Info("Sending reminders")
 
-				err := botApp.SendReminders()
+			reminderScheduler := reminders.NewScheduler(s, usersDao, botApp)
+			err = reminderScheduler.Sync()
+			if err != nil {
+				logger.Get().Error("Failed to load reminder schedules", zap.Error(err))
+				os.Exit(1)
+			}
+			err = reminderScheduler.Start(cfg.Reminders.SyncInterval)
+			if err != nil {
+				logger.Get().Error("Failed to start reminder scheduler", zap.Error(err))
+				os.Exit(1)
+			}
+			_, err = s.Every(1).Sunday().At("17:00").Do(func() {
+				logger.Get().Info("Sending weekly digests")
+
+				err := botApp.SendDigests()
+				if err != nil {
+					logger.Get().Error("Failed to send weekly digests", zap.Error(err))
+				}
+			})
+			if err != nil {
+				logger.Get().Error("Failed to schedule weekly digests", zap.Error(err))
+				os.Exit(1)
+			}
+			_, err = s.Every(1).Minute().Do(func() {
+				counts, err := tasksDao.CountTasksByStatus()
+				if err != nil {
+					logger.Get().Error("Failed to count tasks", zap.Error(err))
+					return
+				}
+				for _, status := range models.TaskStatuses {
+					metrics.BacklogTasks.WithLabelValues(status).Set(float64(counts[status]))
+				}
+			})
+			if err != nil {
+				logger.Get().Error("Failed to schedule backlog metrics", zap.Error(err))
+				os.Exit(1)
+			}
+			if cfg.LinkCheck.Interval > 0 {
+				linkJob := linkcheck.NewJob(tasksDao, linkcheck.NewChecker(cfg.LinkCheck.Timeout), cfg.LinkCheck.Interval, cfg.LinkCheck.Concurrency, linkCheckBatch)
+				_, err = s.Every(10).Minutes().SingletonMode().Do(func() {
+					dead, err := linkJob.Run(context.Background(), time.Now())
+					if err != nil {
+						logger.Get().Error("Link check failed", zap.Error(err))
+						return
+					}
+					if dead > 0 {
+						logger.Get().Info("Found dead links", zap.Int("count", dead))
+					}
+				})
+				if err != nil {
+					logger.Get().Error("Failed to schedule link checks", zap.Error(err))
+					os.Exit(1)
+				}
+			}
+			if cfg.Feeds.Interval > 0 {
+				_, err = s.Every(5).Minutes().SingletonMode().Do(func() {
+					added, err := botApp.PollFeeds(time.Now())
+					if err != nil {
+						logger.Get().Error("Feed polling failed", zap.Error(err))
+						return
+					}
+					if added > 0 {
+						logger.Get().Info("Added feed entries", zap.Int("count", added))
+					}
+				})
+				if err != nil {
+					logger.Get().Error("Failed to schedule feed polling", zap.Error(err))
+					os.Exit(1)
+				}
+			}
+			if cfg.Archive.StaleAfter > 0 {
+				_, err = s.Every(1).Day().At("10:00").Do(func() {
+					logger.Get().Info("Proposing to archive stale tasks")
+
+					err := botApp.ProposeArchiving(time.Now())
+					if err != nil {
+						logger.Get().Error("Failed to propose archiving", zap.Error(err))
+					}
+				})
 				if err != nil {
-					logger.Get().Error("Failed to send reminders", zap.Error(err))
+					logger.Get().Error("Failed to schedule archive proposals", zap.Error(err))
+					os.Exit(1)
 				}
+			}
+			if cfg.Retention.InactiveAfter > 0 {
+				sweeper := retention.NewSweeper(usersDao, tasksDao, cfg.Retention.InactiveAfter)
+				_, err = s.Every(1).Day().At("03:00").Do(func() {
+					deleted, err := sweeper.Sweep(time.Now())
+					if err != nil {
+						logger.Get().Error("Retention sweep failed", zap.Error(err))
+						return
+					}
+					logger.Get().Info("Retention sweep finished", zap.Int("deleted_users", deleted))
+				})
+				if err != nil {
+					logger.Get().Error("Failed to schedule retention sweep", zap.Error(err))
+					os.Exit(1)
+				}
+			}
+			_, err = s.Every(1).Day().At("04:00").Do(func() {
+				deleted, err := linksDao.DeleteExpiredLinks(time.Now())
+				if err != nil {
+					logger.Get().Error("Failed to delete expired links", zap.Error(err))
+					return
+				}
+				logger.Get().Info("Deleted expired links", zap.Int("count", deleted))
 			})
+			if err != nil {
+				logger.Get().Error("Failed to schedule expired link cleanup", zap.Error(err))
+				os.Exit(1)
+			}
 			s.StartAsync()
 
+			// Polling is the only way to tell whether updates still arrive, in
+			// webhook mode readiness depends on the database only.
+			lastPoll := botApp.LastPoll
+			if cfg.Bot.Mode == config.ModeWebhook {
+				lastPoll = nil
+			}
+			httpServer := server.NewServer(cfg.HTTP.Addr, dbConn, lastPoll, 3*time.Minute)
+
+			err = botApp.RegisterCommands()
+			if err != nil {
+				logger.Get().Error("Failed to register bot commands", zap.Error(err))
+			}
+
+			if cfg.Bot.Mode == config.ModeWebhook {
+				httpServer.Handle(cfg.Bot.WebhookPath, botApp.WebhookHandler())
+				err = botApp.SetWebhook(cfg.Bot.WebhookURL)
+				if err != nil {
+					logger.Get().Error("Failed to set webhook", zap.Error(err))
+					os.Exit(1)
+				}
+			} else {
+				err = botApp.DeleteWebhook()
+				if err != nil {
+					logger.Get().Error("Failed to delete webhook", zap.Error(err))
+					os.Exit(1)
+				}
+			}
+			if cfg.API.Enabled {
+				httpServer.Handle(api.Prefix, api.NewHandler(usersDao, tasksDao, tokensDao, botApp))
+			}
+			if cfg.HTTP.PublicURL != "" {
+				httpServer.Handle(listfeed.Prefix, listfeed.NewHandler(usersDao, tasksDao, cfg.HTTP.PublicURL))
+			}
+			httpServer.Start()
+
 			var exit = make(chan os.Signal, 1)
 
-			go func() {
-				botApp.Run()
-			}()
+			if cfg.Bot.Mode == config.ModePolling {
+				go func() {
+					botApp.Run()
+				}()
+			}
 
 			signal.Notify(exit, os.Interrupt)
 
 			<-exit
 
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = httpServer.Shutdown(shutdownCtx)
+			if err != nil {
+				logger.Get().Error("HTTP server shutdown failed", zap.Error(err))
+			}
+
 			logger.Get().Sync()
 		},
 	}
 
+	config.BindFlags(runCmd.Flags())
+
 	return runCmd
 }
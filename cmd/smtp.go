@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/inbox"
+	"tg_bot/pkg/server"
+	"time"
+)
+
+func InitSMTPCommand() *cobra.Command {
+	var smtpCmd = &cobra.Command{
+		Use:   "smtp",
+		Short: "Receive articles mailed to the users' inbox addresses",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).ValidateSMTP)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			ingester := inbox.NewIngester(cfg.SMTP.Domain, dao.NewUsers(dbConn), dao.NewTasks(dbConn), dao.NewSnapshots(dbConn), cfg.SMTP.MaxLinks)
+			smtpServer := inbox.NewServer(cfg.SMTP.Addr, cfg.SMTP.MaxSize, ingester)
+
+			// Metrics and health checks, there are no polls to check.
+			httpServer := server.NewServer(cfg.HTTP.Addr, dbConn, nil, 0)
+			httpServer.Start()
+
+			go func() {
+				err := smtpServer.ListenAndServe()
+				if err != nil {
+					logger.Get().Error("SMTP server failed", zap.Error(err))
+					os.Exit(1)
+				}
+			}()
+
+			var exit = make(chan os.Signal, 1)
+			signal.Notify(exit, os.Interrupt)
+			<-exit
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			err := smtpServer.Shutdown(shutdownCtx)
+			if err != nil {
+				logger.Get().Error("SMTP server shutdown failed", zap.Error(err))
+			}
+			err = httpServer.Shutdown(shutdownCtx)
+			if err != nil {
+				logger.Get().Error("HTTP server shutdown failed", zap.Error(err))
+			}
+		},
+	}
+
+	config.BindFlags(smtpCmd.Flags())
+
+	return smtpCmd
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"text/tabwriter"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+func InitTasksCommand() *cobra.Command {
+	var tasksCmd = &cobra.Command{
+		Use:   "tasks",
+		Short: "Inspect saved articles",
+	}
+
+	var externalId, status string
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the articles of a user",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).ValidateDB)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			user := getUser(dao.NewUsers(dbConn), externalId)
+			tasksDao := dao.NewTasks(dbConn)
+
+			var tasks []*models.Task
+			var err error
+			if status == "" {
+				tasks, err = tasksDao.GetUsersTasks(user.Id)
+			} else {
+				tasks, err = tasksDao.GetUsersTasksByStatus(user.Id, status)
+			}
+			if err != nil {
+				logger.Get().Error("Could not get tasks", zap.Error(err))
+				os.Exit(1)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tCREATED\tUPDATED\tURL")
+			for _, task := range tasks {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", task.Id, task.Status, task.CreatedAt.Format(time.DateTime), task.UpdatedAt.Format(time.DateTime), task.Url)
+			}
+			w.Flush()
+		},
+	}
+	listCmd.Flags().StringVar(&externalId, "user", "", "Telegram ID of the user")
+	listCmd.Flags().StringVar(&status, "status", "", "only list tasks with this status")
+	listCmd.MarkFlagRequired("user")
+
+	tasksCmd.AddCommand(listCmd)
+
+	config.BindFlags(tasksCmd.PersistentFlags())
+
+	return tasksCmd
+}
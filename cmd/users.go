@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"text/tabwriter"
+	"tg_bot/logger"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+func InitUsersCommand() *cobra.Command {
+	var usersCmd = &cobra.Command{
+		Use:   "users",
+		Short: "Inspect bot users",
+	}
+
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List all users",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).ValidateDB)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			users, err := dao.NewUsers(dbConn).GetAllUsers()
+			if err != nil {
+				logger.Get().Error("Could not get users", zap.Error(err))
+				os.Exit(1)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tEXTERNAL ID\tCHAT ID\tLANGUAGE\tCREATED")
+			for _, user := range users {
+				fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", user.Id, user.ExternalId, user.ChatId, user.Language, user.CreatedAt.Format(time.DateTime))
+			}
+			w.Flush()
+		},
+	}
+
+	var showCmd = &cobra.Command{
+		Use:   "show EXTERNAL_ID",
+		Short: "Show a user and their task counts",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadConfig(cmd, (*config.Config).ValidateDB)
+			dbConn := connectDB(&cfg.DB)
+			defer dbConn.Close()
+
+			user := getUser(dao.NewUsers(dbConn), args[0])
+			tasksDao := dao.NewTasks(dbConn)
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "ID:\t%d\n", user.Id)
+			fmt.Fprintf(w, "External ID:\t%s\n", user.ExternalId)
+			fmt.Fprintf(w, "Chat ID:\t%d\n", user.ChatId)
+			fmt.Fprintf(w, "Language:\t%s\n", user.Language)
+			fmt.Fprintf(w, "Reminders:\t%s, %d per reminder\n", user.ReminderSchedule, user.ReminderCount)
+			fmt.Fprintf(w, "Weekly digest:\t%t\n", user.DigestEnabled)
+			fmt.Fprintf(w, "Banned:\t%t\n", user.Banned)
+			fmt.Fprintf(w, "Created:\t%s\n", user.CreatedAt.Format(time.DateTime))
+			for _, status := range models.TaskStatuses {
+				count, err := tasksDao.CountUsersTasksByStatus(user.Id, status)
+				if err != nil {
+					logger.Get().Error("Could not count tasks", zap.Error(err))
+					os.Exit(1)
+				}
+				fmt.Fprintf(w, "Tasks %s:\t%d\n", status, count)
+			}
+			w.Flush()
+		},
+	}
+
+	usersCmd.AddCommand(listCmd, showCmd)
+
+	config.BindFlags(usersCmd.PersistentFlags())
+
+	return usersCmd
+}
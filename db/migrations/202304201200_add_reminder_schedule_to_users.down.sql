@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN reminder_schedule,
+    DROP COLUMN reminder_count;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN reminder_schedule VARCHAR(100) NOT NULL DEFAULT '0 17 * * *',
+    ADD COLUMN reminder_count INT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN digest_enabled BOOLEAN NOT NULL DEFAULT FALSE;
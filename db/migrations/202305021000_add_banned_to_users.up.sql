@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN banned BOOLEAN NOT NULL DEFAULT FALSE;
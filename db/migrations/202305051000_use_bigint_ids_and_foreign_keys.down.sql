@@ -0,0 +1,15 @@
+CREATE INDEX tasks_user_id_index ON tasks (user_id);
+
+ALTER TABLE tasks
+    DROP FOREIGN KEY tasks_user_id_fk;
+
+DROP INDEX tasks_user_id_status_index ON tasks;
+
+ALTER TABLE tasks
+    MODIFY COLUMN id INT NOT NULL AUTO_INCREMENT,
+    MODIFY COLUMN user_id INTEGER NOT NULL;
+
+ALTER TABLE users
+    MODIFY COLUMN id INT NOT NULL AUTO_INCREMENT,
+    MODIFY COLUMN external_id VARCHAR(255) NOT NULL,
+    MODIFY COLUMN chat_id INT NOT NULL;
@@ -0,0 +1,20 @@
+-- Telegram IDs don't fit into 32 bits, group chat IDs look like -100xxxxxxxxxx.
+ALTER TABLE users
+    MODIFY COLUMN id BIGINT NOT NULL AUTO_INCREMENT,
+    MODIFY COLUMN external_id BIGINT NOT NULL,
+    MODIFY COLUMN chat_id BIGINT NOT NULL;
+
+-- The inline REFERENCES of the original table is ignored by MySQL, so tasks
+-- of removed users may be left behind.
+DELETE FROM tasks WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE tasks
+    MODIFY COLUMN id BIGINT NOT NULL AUTO_INCREMENT,
+    MODIFY COLUMN user_id BIGINT NOT NULL;
+
+CREATE INDEX tasks_user_id_status_index ON tasks (user_id, status);
+
+ALTER TABLE tasks
+    ADD CONSTRAINT tasks_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+DROP INDEX tasks_user_id_index ON tasks;
@@ -0,0 +1,4 @@
+DROP INDEX users_last_active_at_index ON users;
+
+ALTER TABLE users
+    DROP COLUMN last_active_at;
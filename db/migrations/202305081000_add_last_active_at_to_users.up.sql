@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN last_active_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+UPDATE users SET last_active_at = updated_at;
+
+CREATE INDEX users_last_active_at_index ON users (last_active_at);
@@ -0,0 +1,4 @@
+UPDATE tasks SET status = 'NEW' WHERE status = 'ARCHIVED';
+
+ALTER TABLE tasks
+    DROP COLUMN archive_proposed_at;
@@ -0,0 +1,2 @@
+ALTER TABLE tasks
+    ADD COLUMN archive_proposed_at TIMESTAMP NULL DEFAULT NULL;
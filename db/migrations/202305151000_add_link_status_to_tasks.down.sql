@@ -0,0 +1,6 @@
+DROP INDEX tasks_status_link_checked_at_index ON tasks;
+
+ALTER TABLE tasks
+    DROP COLUMN link_status,
+    DROP COLUMN link_status_code,
+    DROP COLUMN link_checked_at;
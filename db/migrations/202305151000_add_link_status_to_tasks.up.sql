@@ -0,0 +1,6 @@
+ALTER TABLE tasks
+    ADD COLUMN link_status VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN link_status_code INT NOT NULL DEFAULT 0,
+    ADD COLUMN link_checked_at TIMESTAMP NULL DEFAULT NULL;
+
+CREATE INDEX tasks_status_link_checked_at_index ON tasks (status, link_checked_at);
@@ -0,0 +1,8 @@
+CREATE TABLE task_snapshots (
+    task_id BIGINT PRIMARY KEY,
+    title VARCHAR(500) NOT NULL,
+    -- gzip compressed text
+    content MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT task_snapshots_task_id_fk FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
+);
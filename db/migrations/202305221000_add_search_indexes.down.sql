@@ -0,0 +1,8 @@
+DROP INDEX task_snapshots_search_index ON task_snapshots;
+DROP INDEX tasks_search_index ON tasks;
+
+ALTER TABLE task_snapshots
+    DROP COLUMN search_text;
+
+ALTER TABLE tasks
+    DROP COLUMN tags;
@@ -0,0 +1,11 @@
+ALTER TABLE tasks
+    ADD COLUMN tags VARCHAR(500) NOT NULL DEFAULT '';
+
+-- The snapshot content is compressed, so a bounded plain copy is kept for
+-- searching. Snapshots saved before this migration aren't searchable by
+-- text.
+ALTER TABLE task_snapshots
+    ADD COLUMN search_text TEXT NOT NULL;
+
+CREATE FULLTEXT INDEX tasks_search_index ON tasks (url, tags);
+CREATE FULLTEXT INDEX task_snapshots_search_index ON task_snapshots (title, search_text);
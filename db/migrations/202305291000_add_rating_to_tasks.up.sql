@@ -0,0 +1,2 @@
+ALTER TABLE tasks
+    ADD COLUMN rating INT NOT NULL DEFAULT 0;
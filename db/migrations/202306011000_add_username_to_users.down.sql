@@ -0,0 +1,4 @@
+DROP INDEX users_username_index ON users;
+
+ALTER TABLE users
+    DROP COLUMN username;
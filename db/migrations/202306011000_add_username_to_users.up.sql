@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN username VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE INDEX users_username_index ON users (username);
@@ -0,0 +1,13 @@
+CREATE TABLE shares (
+    token VARCHAR(32) PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    -- recipient_id is set for articles offered to one user, NULL for links
+    -- anyone can open.
+    recipient_id BIGINT NULL,
+    from_name VARCHAR(128) NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    tags VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT shares_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT shares_recipient_id_fk FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE CASCADE
+);
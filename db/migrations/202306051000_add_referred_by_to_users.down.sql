@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP FOREIGN KEY users_referred_by_fk;
+
+ALTER TABLE users
+    DROP COLUMN referred_by;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN referred_by BIGINT NULL,
+    ADD CONSTRAINT users_referred_by_fk FOREIGN KEY (referred_by) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+DROP TABLE team_members;
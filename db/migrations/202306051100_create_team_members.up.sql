@@ -0,0 +1,8 @@
+CREATE TABLE team_members (
+    owner_id BIGINT NOT NULL,
+    member_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (owner_id, member_id),
+    CONSTRAINT team_members_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT team_members_member_id_fk FOREIGN KEY (member_id) REFERENCES users (id) ON DELETE CASCADE
+);
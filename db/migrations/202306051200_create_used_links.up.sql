@@ -0,0 +1,12 @@
+-- A signed link can be used once per user. Rows are only needed until the
+-- link expires.
+CREATE TABLE used_links (
+    id VARCHAR(32) NOT NULL,
+    user_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, user_id),
+    CONSTRAINT used_links_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX used_links_expires_at_index ON used_links (expires_at);
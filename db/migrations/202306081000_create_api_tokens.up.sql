@@ -0,0 +1,11 @@
+-- Only a SHA-256 hash of the token is stored, the token itself is shown to
+-- the user once.
+CREATE TABLE api_tokens (
+    user_id BIGINT PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NULL,
+    CONSTRAINT api_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX api_tokens_token_hash_index ON api_tokens (token_hash);
@@ -0,0 +1,4 @@
+DROP INDEX users_inbox_token_index ON users;
+
+ALTER TABLE users
+    DROP COLUMN inbox_token;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN inbox_token VARCHAR(32) NULL;
+
+CREATE UNIQUE INDEX users_inbox_token_index ON users (inbox_token);
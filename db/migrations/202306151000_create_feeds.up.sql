@@ -0,0 +1,18 @@
+-- seen_entries are the keys of the entries in the feed at the last check,
+-- separated by spaces. Only entries not among them are added.
+CREATE TABLE feeds (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    etag VARCHAR(255) NOT NULL DEFAULT '',
+    last_modified VARCHAR(64) NOT NULL DEFAULT '',
+    seen_entries TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    checked_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT feeds_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX feeds_user_id_url_index ON feeds (user_id, url);
+CREATE INDEX feeds_checked_at_index ON feeds (checked_at);
@@ -0,0 +1,4 @@
+DROP INDEX users_feed_token_hash_index ON users;
+
+ALTER TABLE users
+    DROP COLUMN feed_token_hash;
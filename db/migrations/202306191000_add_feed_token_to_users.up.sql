@@ -0,0 +1,5 @@
+-- Only a SHA-256 hash of the feed token is stored, like for API tokens.
+ALTER TABLE users
+    ADD COLUMN feed_token_hash CHAR(64) NULL;
+
+CREATE UNIQUE INDEX users_feed_token_hash_index ON users (feed_token_hash);
@@ -2,27 +2,117 @@ package db
 
 import (
 	"embed"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"io/fs"
+	"os"
 )
 
 //go:embed migrations
-var fs embed.FS
+var migrationsFs embed.FS
 
 type Migrator struct {
 	dbUrl string
 }
 
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Status struct {
+	// Version is the current schema version, zero if no migration was
+	// applied yet.
+	Version    uint
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
 func NewMigrator(dbUrl string) *Migrator {
 	return &Migrator{
 		dbUrl: dbUrl,
 	}
 }
 
+// Migrate applies all pending migrations.
 func (m *Migrator) Migrate() error {
-	d, err := iofs.New(fs, "migrations")
+	return m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Up()
+	})
+}
+
+// Down rolls back the given number of migrations, or all of them if steps
+// is not positive.
+func (m *Migrator) Down(steps int) error {
+	return m.run(func(migrator *migrate.Migrate) error {
+		if steps <= 0 {
+			return migrator.Down()
+		}
+		return migrator.Steps(-steps)
+	})
+}
+
+// Goto migrates up or down to the given version.
+func (m *Migrator) Goto(version uint) error {
+	return m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Migrate(version)
+	})
+}
+
+// Force sets the version without running migrations, it is used to clear
+// the dirty flag after fixing a failed migration by hand. -1 means no
+// version.
+func (m *Migrator) Force(version int) error {
+	return m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Force(version)
+	})
+}
+
+func (m *Migrator) Status() (*Status, error) {
+	status := &Status{}
+	err := m.run(func(migrator *migrate.Migrate) error {
+		version, dirty, err := migrator.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version = version
+		status.Dirty = dirty
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := iofs.New(migrationsFs, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	version, err := d.First()
+	for err == nil {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: version,
+			Name:    migrationName(d, version),
+			Applied: version <= status.Version,
+		})
+		version, err = d.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func (m *Migrator) run(f func(migrator *migrate.Migrate) error) error {
+	d, err := iofs.New(migrationsFs, "migrations")
 	if err != nil {
 		return err
 	}
@@ -35,11 +125,17 @@ func (m *Migrator) Migrate() error {
 	if err != nil {
 		return err
 	}
+	defer migrator.Close()
 
-	err = migrator.Up()
+	return f(migrator)
+}
+
+func migrationName(d source.Driver, version uint) string {
+	r, identifier, err := d.ReadUp(version)
 	if err != nil {
-		return err
+		return ""
 	}
+	r.Close()
 
-	return nil
+	return identifier
 }
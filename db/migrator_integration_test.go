@@ -0,0 +1,216 @@
+//go:build integration
+
+package db
+
+import (
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"os"
+	"testing"
+)
+
+// The tests run the migrations against a real MySQL. TG_BOT_TEST_DSN is the
+// DSN of an empty database the tests may drop everything in, e.g.
+//
+//	TG_BOT_TEST_DSN='root:secret@tcp(localhost:3306)/read_that_bot_test' \
+//		go test -tags integration ./db/
+const testDSNEnv = "TG_BOT_TEST_DSN"
+
+const (
+	beforeBigintVersion = 202305021000
+	bigintVersion       = 202305051000
+)
+
+func openTestDB(t *testing.T) (*Migrator, *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	err = conn.Ping()
+	if err != nil {
+		t.Skipf("MySQL is unavailable: %v", err)
+	}
+
+	migrator := NewMigrator(dsn)
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Version != 0 {
+		t.Fatalf("the test database is at version %d, it must be empty", status.Version)
+	}
+	t.Cleanup(func() {
+		err := migrator.Down(0)
+		if err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	})
+
+	return migrator, conn
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	migrator, _ := openTestDB(t)
+
+	err := migrator.Migrate()
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	err = migrator.Down(0)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Version != 0 || status.Dirty {
+		t.Fatalf("after down got version %d, dirty %v", status.Version, status.Dirty)
+	}
+
+	err = migrator.Migrate()
+	if err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
+
+func TestBigintIdsAndForeignKeys(t *testing.T) {
+	migrator, conn := openTestDB(t)
+
+	err := migrator.Goto(beforeBigintVersion)
+	if err != nil {
+		t.Fatalf("goto %d: %v", beforeBigintVersion, err)
+	}
+
+	mustExec(t, conn, `INSERT INTO users (id, external_id, chat_id) VALUES (1, '42', 42)`)
+	mustExec(t, conn, `INSERT INTO tasks (user_id, url, status) VALUES (1, 'https://example.com/kept', 'new')`)
+	// Left behind by a removed user, the migration drops it.
+	mustExec(t, conn, `INSERT INTO tasks (user_id, url, status) VALUES (2, 'https://example.com/orphan', 'new')`)
+
+	err = migrator.Goto(bigintVersion)
+	if err != nil {
+		t.Fatalf("goto %d: %v", bigintVersion, err)
+	}
+
+	if n := count(t, conn, `SELECT COUNT(*) FROM tasks WHERE user_id = 2`); n != 0 {
+		t.Errorf("orphaned tasks: got %d, want 0", n)
+	}
+	if n := count(t, conn, `SELECT COUNT(*) FROM tasks WHERE user_id = 1`); n != 1 {
+		t.Errorf("tasks of user 1: got %d, want 1", n)
+	}
+
+	t.Run("chat id below -2^31", func(t *testing.T) {
+		const chatId = int64(-1001234567890)
+		const externalId = int64(5000000000)
+		mustExec(t, conn, `INSERT INTO users (id, external_id, chat_id) VALUES (2, ?, ?)`, externalId, chatId)
+
+		var gotChat, gotExternal int64
+		err := conn.QueryRow(`SELECT chat_id, external_id FROM users WHERE id = 2`).Scan(&gotChat, &gotExternal)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if gotChat != chatId || gotExternal != externalId {
+			t.Errorf("got chat_id %d, external_id %d, want %d, %d", gotChat, gotExternal, chatId, externalId)
+		}
+	})
+
+	t.Run("foreign key", func(t *testing.T) {
+		_, err := conn.Exec(`INSERT INTO tasks (user_id, url, status) VALUES (999, 'https://example.com/', 'new')`)
+		if err == nil {
+			t.Errorf("inserted a task of a missing user")
+		}
+	})
+
+	t.Run("cascade delete", func(t *testing.T) {
+		mustExec(t, conn, `INSERT INTO users (id, external_id, chat_id) VALUES (3, '3', 3)`)
+		mustExec(t, conn, `INSERT INTO tasks (user_id, url, status) VALUES (3, 'https://example.com/a', 'new'), (3, 'https://example.com/b', 'done')`)
+		mustExec(t, conn, `DELETE FROM users WHERE id = 3`)
+
+		if n := count(t, conn, `SELECT COUNT(*) FROM tasks WHERE user_id = 3`); n != 0 {
+			t.Errorf("tasks of the deleted user: got %d, want 0", n)
+		}
+	})
+
+	t.Run("status index", func(t *testing.T) {
+		rows, err := conn.Query(`EXPLAIN SELECT id FROM tasks WHERE user_id = 1 AND status = 'new'`)
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("columns: %v", err)
+		}
+		if !rows.Next() {
+			t.Fatalf("explain returned no rows")
+		}
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		for i, column := range columns {
+			if column == "key" && values[i].String != "tasks_user_id_status_index" {
+				t.Errorf("query uses index %q, want tasks_user_id_status_index", values[i].String)
+			}
+		}
+	})
+
+	// Rows that don't fit into INT would make the down migration fail.
+	mustExec(t, conn, `DELETE FROM users WHERE id = 2`)
+
+	err = migrator.Goto(beforeBigintVersion)
+	if err != nil {
+		t.Fatalf("down to %d: %v", beforeBigintVersion, err)
+	}
+
+	var chatType string
+	err = conn.QueryRow(`SELECT DATA_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'chat_id'`).Scan(&chatType)
+	if err != nil {
+		t.Fatalf("column type: %v", err)
+	}
+	if chatType != "int" {
+		t.Errorf("chat_id after down: got %s, want int", chatType)
+	}
+	if n := count(t, conn, `SELECT COUNT(*) FROM tasks WHERE user_id = 1`); n != 1 {
+		t.Errorf("tasks of user 1 after down: got %d, want 1", n)
+	}
+}
+
+func mustExec(t *testing.T, conn *sql.DB, query string, args ...any) {
+	t.Helper()
+
+	_, err := conn.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func count(t *testing.T, conn *sql.DB, query string) int {
+	t.Helper()
+
+	var n int
+	err := conn.QueryRow(query).Scan(&n)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+
+	return n
+}
@@ -2,10 +2,13 @@ package logger
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var _logger *zap.Logger
 
+var level = zap.NewAtomicLevelAt(zap.InfoLevel)
+
 func init() {
 	logger, err := NewLogger()
 	if err != nil {
@@ -16,7 +19,10 @@ func init() {
 }
 
 func NewLogger() (*zap.Logger, error) {
-	logger, err := zap.NewProduction()
+	config := zap.NewProductionConfig()
+	config.Level = level
+
+	logger, err := config.Build()
 	if err != nil {
 		return nil, err
 	}
@@ -26,3 +32,15 @@ func NewLogger() (*zap.Logger, error) {
 func Get() *zap.Logger {
 	return _logger
 }
+
+// SetLevel changes the level of the global logger, e.g. "debug".
+func SetLevel(text string) error {
+	var l zapcore.Level
+	err := l.UnmarshalText([]byte(text))
+	if err != nil {
+		return err
+	}
+	level.SetLevel(l)
+
+	return nil
+}
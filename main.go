@@ -12,6 +12,13 @@ func main() {
 
 	rootCmd.AddCommand(
 		cmd.InitRunCommand(),
+		cmd.InitMigrateCommand(),
+		cmd.InitUsersCommand(),
+		cmd.InitTasksCommand(),
+		cmd.InitBroadcastCommand(),
+		cmd.InitRemindersCommand(),
+		cmd.InitDBCommand(),
+		cmd.InitSMTPCommand(),
 	)
 
 	err := rootCmd.Execute()
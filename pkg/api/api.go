@@ -0,0 +1,308 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	Prefix = "/api/v1/"
+
+	maxBodySize = 64 << 10
+)
+
+// Adder adds articles the same way the bot does, including their
+// snapshots.
+type Adder interface {
+	AddTask(userId int64, taskUrl string, tags []string) (*models.Task, error)
+}
+
+type Task struct {
+	Id        int64     `json:"id"`
+	Url       string    `json:"url"`
+	Status    string    `json:"status"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type addRequest struct {
+	Url  string   `json:"url"`
+	Tags []string `json:"tags"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the REST API:
+//
+//	GET  /api/v1/tasks[?status=NEW]  lists the user's articles
+//	POST /api/v1/tasks               adds {"url": "...", "tags": ["..."]}
+//	POST /api/v1/tasks/{id}/done     marks an article as read
+//
+// Requests are authenticated with "Authorization: Bearer <token>", users
+// get their token with /token. Cookies are never used, so any origin may
+// call the API, which browser extensions need.
+type Handler struct {
+	usersDao  dao.Users
+	tasksDao  dao.Tasks
+	tokensDao dao.Tokens
+	adder     Adder
+}
+
+func NewHandler(usersDao dao.Users, tasksDao dao.Tasks, tokensDao dao.Tokens, adder Adder) *Handler {
+	return &Handler{
+		usersDao:  usersDao,
+		tasksDao:  tasksDao,
+		tokensDao: tokensDao,
+		adder:     adder,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	endpoint := "unknown"
+	defer func() {
+		metrics.APIRequests.WithLabelValues(endpoint, strconv.Itoa(rw.status)).Inc()
+	}()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "tasks":
+		endpoint = "tasks"
+		switch r.Method {
+		case http.MethodGet:
+			h.withUser(rw, r, h.listTasks)
+		case http.MethodPost:
+			h.withUser(rw, r, h.addTask)
+		default:
+			writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case len(parts) == 3 && parts[0] == "tasks" && parts[2] == "done":
+		endpoint = "tasks_done"
+		if r.Method != http.MethodPost {
+			writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.withUser(rw, r, func(w http.ResponseWriter, r *http.Request, user *models.User) {
+			h.completeTask(w, user, parts[1])
+		})
+	default:
+		writeError(rw, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) withUser(w http.ResponseWriter, r *http.Request, next func(w http.ResponseWriter, r *http.Request, user *models.User)) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		writeError(w, http.StatusUnauthorized, "missing bearer token")
+		return
+	}
+
+	userId, err := h.tokensDao.GetUserIdByTokenHash(HashToken(strings.TrimSpace(token)))
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	user, err := h.usersDao.GetUserById(userId)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	if user.Banned {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	// Using the API counts as activity, retention would delete the user
+	// otherwise.
+	err = h.usersDao.UpdateUserLastActive(user.Id)
+	if err != nil {
+		logger.Get().Error("Could not update last activity", zap.Int64("user_id", user.Id), zap.Error(err))
+	}
+
+	next(w, r, user)
+}
+
+func (h *Handler) listTasks(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var tasks []*models.Task
+	var err error
+	if status := r.URL.Query().Get("status"); status != "" {
+		if !validStatus(status) {
+			writeError(w, http.StatusBadRequest, "unknown status")
+			return
+		}
+		tasks, err = h.tasksDao.GetUsersTasksByStatus(user.Id, status)
+	} else {
+		tasks, err = h.tasksDao.GetUsersTasks(user.Id)
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	list := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		list = append(list, toTask(task))
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) addTask(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req addRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	req.Url = strings.TrimSpace(req.Url)
+	if req.Url == "" || strings.ContainsAny(req.Url, " \t\n") {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+	if utf8.RuneCountInString(req.Url) > models.MaxUrlLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("url is too long, at most %d characters", models.MaxUrlLength))
+		return
+	}
+
+	task, err := h.adder.AddTask(user.Id, req.Url, normalizeTags(req.Tags))
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toTask(task))
+}
+
+func (h *Handler) completeTask(w http.ResponseWriter, user *models.User, taskIdStr string) {
+	taskId, err := strconv.ParseInt(taskIdStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	task, err := h.tasksDao.GetTaskById(taskId)
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && task.UserId != user.Id) {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	if task.Status != models.TaskStatusDone {
+		err = h.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusDone)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		task.Status = models.TaskStatusDone
+		task.UpdatedAt = time.Now()
+	}
+
+	writeJSON(w, http.StatusOK, toTask(task))
+}
+
+func validStatus(status string) bool {
+	for _, s := range models.TaskStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeTags accepts tags with or without "#", the same way they are
+// written after /add.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || strings.ContainsAny(tag, " \t\n") || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+func toTask(task *models.Task) Task {
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return Task{
+		Id:        task.Id,
+		Url:       task.Url,
+		Status:    task.Status,
+		Tags:      tags,
+		CreatedAt: task.CreatedAt,
+		UpdatedAt: task.UpdatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Get().Warn("Could not write API response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	refErr := errs.NewErrWithRef(err)
+	logger.Get().Error("API request failed", zap.String("error_ref", refErr.Ref), zap.Error(err))
+	writeError(w, http.StatusInternalServerError, "internal error, reference "+refErr.Ref)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	tokenPrefix = "rtb_"
+	tokenBytes  = 32
+)
+
+// NewToken returns a random API token and the hash it is stored as.
+func NewToken() (string, string, error) {
+	raw := make([]byte, tokenBytes)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	return token, HashToken(token), nil
+}
+
+// HashToken is a plain SHA-256, tokens are random enough not to need a
+// slow hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
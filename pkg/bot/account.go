@@ -0,0 +1,75 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strconv"
+	"strings"
+	"tg_bot/pkg/retention"
+	"time"
+)
+
+const (
+	forgetConfirm = "confirm"
+	forgetCancel  = "cancel"
+)
+
+// HandleMyDataCmd sends everything stored about the user as a JSON file.
+func (b *Bot) HandleMyDataCmd(ctx *Context) error {
+	e, err := b.exports.Generate(ctx.User, time.Now())
+	if err != nil {
+		return err
+	}
+
+	data, err := e.JSON()
+	if err != nil {
+		return err
+	}
+
+	doc := tgbotapi.NewDocument(ctx.ChatId, tgbotapi.FileBytes{Name: "my_data.json", Bytes: data})
+	doc.Caption = b.t(ctx.Lang, "my_data.caption")
+	_, err = b.botApi.Send(doc)
+
+	return err
+}
+
+// HandleForgetMeCmd asks for confirmation before deleting the account. The
+// buttons carry the user ID, so in a group only the user who asked can
+// confirm.
+func (b *Bot) HandleForgetMeCmd(ctx *Context) error {
+	userId := strconv.FormatInt(ctx.User.Id, 10)
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, b.t(ctx.Lang, "forget.confirm"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "forget.button_confirm"), CallbackData("forget_me", forgetConfirm+":"+userId)),
+		tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "forget.button_cancel"), CallbackData("forget_me", forgetCancel+":"+userId)),
+	))
+	_, err := b.botApi.Send(msg)
+
+	return err
+}
+
+func (b *Bot) HandleForgetMeCallback(ctx *Context) error {
+	action, userId, _ := strings.Cut(ctx.Args, ":")
+	if userId != strconv.FormatInt(ctx.User.Id, 10) {
+		return nil
+	}
+
+	if action != forgetConfirm {
+		return b.editMessage(ctx, b.t(ctx.Lang, "forget.cancelled"))
+	}
+
+	err := retention.Forget(b.usersDao, b.tasksDao, ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	return b.editMessage(ctx, b.t(ctx.Lang, "forget.done"))
+}
+
+// editMessage replaces the text of the message a pressed button belongs to
+// and removes its buttons.
+func (b *Bot) editMessage(ctx *Context, text string) error {
+	_, err := b.botApi.Send(tgbotapi.NewEditMessageText(ctx.ChatId, ctx.Message.MessageID, text))
+
+	return err
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"go.uber.org/zap"
+	"strconv"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+)
+
+func (b *Bot) isAdmin(tgUserId int64) bool {
+	for _, id := range b.cfg.Bot.Admins {
+		if id == tgUserId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Bot) HandleAdminStatsCmd(ctx *Context) error {
+	usersCount, err := b.usersDao.CountUsers()
+	if err != nil {
+		return err
+	}
+
+	counts, err := b.tasksDao.CountTasksByStatus()
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "admin.stats", i18n.Data{
+		"Users":      usersCount,
+		"New":        counts[models.TaskStatusNew],
+		"InProgress": counts[models.TaskStatusInProgress],
+		"Done":       counts[models.TaskStatusDone],
+		"Archived":   counts[models.TaskStatusArchived],
+	}))
+}
+
+// HandleBroadcastCmd sends the arguments to every user. Sending is rate
+// limited and can take a while, so it runs in the background and the admin
+// is told about the result at the end.
+func (b *Bot) HandleBroadcastCmd(ctx *Context) error {
+	if ctx.Args == "" {
+		return errs.NewErrUser("admin.broadcast_empty", nil, nil)
+	}
+
+	err := b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "admin.broadcast_started"))
+	if err != nil {
+		return err
+	}
+
+	chatId, lang, text := ctx.ChatId, ctx.Lang, ctx.Args
+	go func() {
+		sent, failed, err := b.Broadcast(text)
+		if err != nil {
+			logger.Get().Error("Broadcast failed", zap.Error(err))
+		}
+
+		err = b.SendMessage(chatId, b.t(lang, "admin.broadcast_finished", i18n.Data{"Sent": sent, "Failed": failed}))
+		if err != nil {
+			logger.Get().Error("Could not send message", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+func (b *Bot) HandleBanCmd(ctx *Context) error {
+	return b.setBanned(ctx, true)
+}
+
+func (b *Bot) HandleUnbanCmd(ctx *Context) error {
+	return b.setBanned(ctx, false)
+}
+
+// setBanned bans or unbans the user with the Telegram ID given in the
+// arguments. Users who never talked to the bot are created, so they can be
+// banned up front; their private chat ID is the same as their user ID.
+func (b *Bot) setBanned(ctx *Context, banned bool) error {
+	tgUserId, err := strconv.ParseInt(ctx.Args, 10, 64)
+	if err != nil {
+		return errs.NewErrUser("admin.ban_invalid", nil, err)
+	}
+	if banned && b.isAdmin(tgUserId) {
+		return errs.NewErrUser("admin.ban_admin", nil, nil)
+	}
+
+	user, err := b.ensureUserExists(strconv.FormatInt(tgUserId, 10), tgUserId)
+	if err != nil {
+		return err
+	}
+
+	err = b.usersDao.UpdateUserBanned(user.Id, banned)
+	if err != nil {
+		return err
+	}
+
+	key := "admin.unbanned"
+	if banned {
+		key = "admin.banned"
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, key, i18n.Data{"Id": tgUserId}))
+}
+
+func (b *Bot) HandleRemindersNowCmd(ctx *Context) error {
+	err := b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "admin.reminders_started"))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := b.SendReminders()
+		if err != nil {
+			logger.Get().Error("Could not send reminders", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
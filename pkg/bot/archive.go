@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+const (
+	// archiveProposals limits how many stale articles a user is asked
+	// about in one run.
+	archiveProposals = 3
+	archivedLimit    = 10
+
+	archiveActionArchive = "archive"
+	archiveActionKeep    = "keep"
+)
+
+func (b *Bot) HandleArchiveCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		return errs.NewErrUser("tasks.none_in_progress", nil, nil)
+	}
+
+	var taskIds []int64
+	for _, task := range tasks {
+		taskIds = append(taskIds, task.Id)
+	}
+
+	err = b.tasksDao.UpdateTasksStatus(taskIds, models.TaskStatusArchived)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "archive.success", i18n.Data{"Url": tasks[0].Url}))
+}
+
+// HandleArchivedCmd lists the recently archived articles with a restore
+// button for each.
+func (b *Bot) HandleArchivedCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetUsersRecentTasksByStatus(ctx.User.Id, models.TaskStatusArchived, archivedLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		return errs.NewErrUser("archived.none", nil, nil)
+	}
+
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	text.WriteString(b.t(ctx.Lang, "archived.title"))
+	text.WriteString("\n")
+	for i, task := range tasks {
+		text.WriteString(fmt.Sprintf("%d. %s\n", i+1, task.Url))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			b.t(ctx.Lang, "archived.button_restore", i18n.Data{"Number": i + 1}),
+			CallbackData("restore", strconv.FormatInt(task.Id, 10)),
+		)))
+	}
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, text.String())
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err = b.botApi.Send(msg)
+
+	return err
+}
+
+func (b *Bot) HandleRestoreCallback(ctx *Context) error {
+	task, err := b.getUsersTask(ctx.User, ctx.Args)
+	if err != nil {
+		return err
+	}
+
+	if task.Status != models.TaskStatusArchived {
+		return nil
+	}
+
+	err = b.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusNew)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "archived.restored", i18n.Data{"Url": task.Url}))
+}
+
+// HandleArchiveCallback handles the answer to an archive proposal.
+func (b *Bot) HandleArchiveCallback(ctx *Context) error {
+	action, taskId, _ := strings.Cut(ctx.Args, ":")
+	task, err := b.getUsersTask(ctx.User, taskId)
+	if err != nil {
+		return err
+	}
+
+	if action != archiveActionArchive {
+		return b.editMessage(ctx, b.t(ctx.Lang, "archive.kept", i18n.Data{"Url": task.Url}))
+	}
+
+	if task.Status == models.TaskStatusNew {
+		err = b.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusArchived)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.editMessage(ctx, b.t(ctx.Lang, "archive.archived", i18n.Data{"Url": task.Url}))
+}
+
+// ProposeArchiving asks every user whether to archive their unread articles
+// older than the configured age. Articles are proposed again only after
+// another such period.
+func (b *Bot) ProposeArchiving(now time.Time) error {
+	users, err := b.usersDao.GetAllUsers()
+	if err != nil {
+		logger.Get().Error("Could not get users", zap.Error(err))
+		return err
+	}
+
+	for _, user := range users {
+		if user.Banned {
+			continue
+		}
+
+		err = b.proposeUserArchiving(user, now)
+		if err != nil {
+			logger.Get().Error("Could not propose archiving", zap.Int64("user_id", user.Id), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+func (b *Bot) proposeUserArchiving(user *models.User, now time.Time) error {
+	tasks, err := b.tasksDao.GetUsersStaleTasks(user.Id, now.Add(-b.cfg.Archive.StaleAfter), archiveProposals)
+	if err != nil {
+		return err
+	}
+
+	lang := b.locale(user, nil)
+	for _, task := range tasks {
+		err = b.reminderLimiter.Wait(context.Background())
+		if err != nil {
+			return err
+		}
+
+		taskId := strconv.FormatInt(task.Id, 10)
+		days := int(now.Sub(task.CreatedAt).Hours() / 24)
+
+		msg := tgbotapi.NewMessage(user.ChatId, b.plural(lang, "archive.propose", days, i18n.Data{"Url": task.Url}))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.t(lang, "archive.button_keep"), CallbackData("archive", archiveActionKeep+":"+taskId)),
+			tgbotapi.NewInlineKeyboardButtonData(b.t(lang, "archive.button_archive"), CallbackData("archive", archiveActionArchive+":"+taskId)),
+		))
+		_, err = b.botApi.Send(msg)
+		metrics.ObserveReminder(err)
+		if err != nil {
+			return err
+		}
+
+		err = b.tasksDao.MarkTasksArchiveProposed([]int64{task.Id})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getUsersTask loads a task by the ID from callback data and makes sure it
+// belongs to the user.
+func (b *Bot) getUsersTask(user *models.User, taskId string) (*models.Task, error) {
+	id, err := strconv.ParseInt(taskId, 10, 64)
+	if err != nil {
+		return nil, errs.NewErrNotFound("Task", "id", taskId)
+	}
+
+	task, err := b.tasksDao.GetTaskById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.UserId != user.Id {
+		return nil, errs.NewErrNotFound("Task", "id", taskId)
+	}
+
+	return task, nil
+}
@@ -1,195 +1,490 @@
 package bot
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"net/http"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"tg_bot/logger"
+	"tg_bot/pkg/config"
 	"tg_bot/pkg/dao"
+	"tg_bot/pkg/deeplink"
+	"tg_bot/pkg/digest"
 	"tg_bot/pkg/errs"
+	"tg_bot/pkg/export"
+	"tg_bot/pkg/feeds"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/metrics"
 	"tg_bot/pkg/models"
+	"tg_bot/pkg/reminders"
+	"tg_bot/pkg/snapshot"
+	"time"
+	"unicode/utf8"
 )
 
+// Telegram allows about 30 messages per second across all chats, reminders
+// are sent a bit below that to leave room for regular replies.
+const reminderRate = 25
+
+const (
+	pollTimeout      = 60
+	pollRetryDelay   = 3 * time.Second
+	maxPollFailures  = 5
+	minRestartDelay  = time.Second
+	maxRestartDelay  = time.Minute
+	stableLoopPeriod = 5 * time.Minute
+)
+
+// maxMessageLength is the longest text Telegram takes in one message, in
+// UTF-16 code units.
+const maxMessageLength = 4096
+
+// webhookSecretHeader carries the secret_token given to setWebhook.
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
 type Bot struct {
-	key      string
-	botApi   *tgbotapi.BotAPI
-	usersDao dao.Users
-	tasksDao dao.Tasks
+	cfg          *config.Config
+	botApi       *tgbotapi.BotAPI
+	usersDao     dao.Users
+	tasksDao     dao.Tasks
+	snapshotsDao dao.Snapshots
+	notesDao     dao.Notes
+	sharesDao    dao.Shares
+	teamsDao     dao.Teams
+	linksDao     dao.Links
+	tokensDao    dao.Tokens
+	feedsDao     dao.Feeds
+	// links is nil if no links secret is configured.
+	links *deeplink.Signer
+	// snapshots is nil if saving snapshots is turned off.
+	snapshots *snapshot.Fetcher
+	// snapshotQueue holds the added articles waiting for their snapshots.
+	snapshotQueue chan *models.Task
+	// feeds is nil if feed subscriptions are turned off.
+	feeds           *feeds.Poller
+	reminderLimiter *rate.Limiter
+	digests         *digest.Generator
+	exports         *export.Generator
+	catalog         *i18n.Catalog
+	router          *Router
+	takeaways       *takeawayPrompts
+	updatesOffset   int
+	lastPoll        atomic.Int64
 }
 
-func NewBot(key string, usersDao dao.Users, tasksDao dao.Tasks) (*Bot, error) {
-	bot, err := tgbotapi.NewBotAPI(key)
+func NewBot(cfg *config.Config, usersDao dao.Users, tasksDao dao.Tasks, snapshotsDao dao.Snapshots, notesDao dao.Notes, sharesDao dao.Shares, teamsDao dao.Teams, linksDao dao.Links, tokensDao dao.Tokens, feedsDao dao.Feeds) (*Bot, error) {
+	catalog, err := i18n.Load()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Bot{
-		key:      key,
-		botApi:   bot,
-		usersDao: usersDao,
-		tasksDao: tasksDao,
-	}, nil
+	bot, err := tgbotapi.NewBotAPI(cfg.Bot.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	bot.Debug = cfg.Bot.Debug
+
+	b := &Bot{
+		cfg:             cfg,
+		botApi:          bot,
+		usersDao:        usersDao,
+		tasksDao:        tasksDao,
+		snapshotsDao:    snapshotsDao,
+		notesDao:        notesDao,
+		sharesDao:       sharesDao,
+		teamsDao:        teamsDao,
+		linksDao:        linksDao,
+		tokensDao:       tokensDao,
+		feedsDao:        feedsDao,
+		reminderLimiter: rate.NewLimiter(rate.Every(time.Second/reminderRate), 1),
+		digests:         digest.NewGenerator(tasksDao),
+		exports:         export.NewGenerator(usersDao, tasksDao, notesDao, sharesDao, teamsDao, tokensDao, feedsDao),
+		catalog:         catalog,
+		takeaways:       newTakeawayPrompts(),
+	}
+	if cfg.Snapshots.Enabled {
+		b.snapshots = snapshot.NewFetcher(cfg.Snapshots.Timeout, int64(cfg.Snapshots.MaxSize))
+		b.snapshotQueue = make(chan *models.Task, snapshotQueueSize)
+		for i := 0; i < snapshotWorkers; i++ {
+			go b.saveSnapshots()
+		}
+	}
+	if cfg.Links.Secret != "" {
+		b.links = deeplink.NewSigner(cfg.Links.Secret)
+	}
+	if cfg.Feeds.Interval > 0 {
+		fetcher := feeds.NewFetcher(cfg.Feeds.Timeout, int64(cfg.Feeds.MaxSize))
+		b.feeds = feeds.NewPoller(usersDao, feedsDao, tasksDao, fetcher, cfg.Feeds.Interval, cfg.Feeds.MaxEntries, feedPollBatch)
+	}
+	b.router = b.newRouter()
+
+	return b, nil
 }
 
+func (b *Bot) newRouter() *Router {
+	r := NewRouter()
+	r.Use(
+		Metrics(),
+		Logging(),
+		b.ErrorReply(),
+		Recover(),
+		b.RateLimit(),
+		b.ResolveUser(),
+	)
+
+	r.Handle("start", "command.start", b.HandleStartCmd).Hidden = true
+	r.Handle("help", "command.help", b.HandleHelpCmd)
+	r.Handle("add", "command.add", b.HandleAddCmd)
+	r.Handle("share", "command.share", b.HandleShareCmd)
+	r.Handle("team", "command.team", b.HandleTeamCmd)
+	r.Handle("invite", "command.invite", b.HandleInviteCmd)
+	r.Handle("current", "command.current", b.HandleCurrentCmd)
+	r.Handle("done", "command.done", b.HandleDoneCmd)
+	r.Handle("next", "command.next", b.HandleNextCmd)
+	r.Handle("skip", "command.skip", b.HandleSkipCmd)
+	r.Handle("list", "command.list", b.HandleListCmd)
+	r.Handle("stats", "command.stats", b.HandleStatsCmd)
+	r.Handle("snapshot", "command.snapshot", b.HandleSnapshotCmd)
+	r.Handle("search", "command.search", b.HandleSearchCmd)
+	r.Handle("note", "command.note", b.HandleNoteCmd)
+	r.HandleText(b.HandleText)
+	r.Handle("archive", "command.archive", b.HandleArchiveCmd)
+	r.Handle("archived", "command.archived", b.HandleArchivedCmd)
+	r.Handle("schedule", "command.schedule", b.HandleScheduleCmd)
+	r.Handle("digest", "command.digest", b.HandleDigestCmd)
+	r.Handle("lang", "command.lang", b.HandleLangCmd)
+	private := b.PrivateOnly()
+	r.Handle("my_data", "command.my_data", b.HandleMyDataCmd, private)
+	r.Handle("forget_me", "command.forget_me", b.HandleForgetMeCmd)
+	if b.cfg.API.Enabled {
+		r.Handle("token", "command.token", b.HandleTokenCmd, private)
+	}
+	if b.cfg.SMTP.Domain != "" {
+		r.Handle("email", "command.email", b.HandleEmailCmd, private)
+	}
+	if b.cfg.HTTP.PublicURL != "" {
+		r.Handle("rss", "command.rss", b.HandleRssCmd, private)
+	}
+	if b.feeds != nil {
+		r.Handle("subscribe", "command.subscribe", b.HandleSubscribeCmd)
+		r.Handle("feeds", "command.feeds", b.HandleFeedsCmd)
+		r.Handle("unsubscribe", "command.unsubscribe", b.HandleUnsubscribeCmd)
+	}
+	r.HandleCallback("forget_me", b.HandleForgetMeCallback)
+	r.HandleCallback("archive", b.HandleArchiveCallback)
+	r.HandleCallback("restore", b.HandleRestoreCallback)
+	r.HandleCallback("search", b.HandleSearchPageCallback)
+	r.HandleCallback("read", b.HandleReadCallback)
+	r.HandleCallback("delete", b.HandleDeleteCallback)
+	r.HandleCallback("rate", b.HandleRateCallback)
+	r.HandleCallback("share", b.HandleShareCallback)
+
+	admin := b.AdminOnly()
+	r.Handle("admin_stats", "command.admin_stats", b.HandleAdminStatsCmd, admin).Hidden = true
+	r.Handle("broadcast", "command.broadcast", b.HandleBroadcastCmd, admin).Hidden = true
+	r.Handle("ban", "command.ban", b.HandleBanCmd, admin).Hidden = true
+	r.Handle("unban", "command.unban", b.HandleUnbanCmd, admin).Hidden = true
+	r.Handle("reminders_now", "command.reminders_now", b.HandleRemindersNowCmd, admin).Hidden = true
+
+	return r
+}
+
+// RegisterCommands publishes the command menu to Telegram, once per
+// available locale.
+func (b *Bot) RegisterCommands() error {
+	for _, lang := range b.catalog.Locales() {
+		var commands []tgbotapi.BotCommand
+		for _, cmd := range b.router.Commands() {
+			commands = append(commands, tgbotapi.BotCommand{
+				Command:     cmd.Name,
+				Description: b.t(lang, cmd.Description),
+			})
+		}
+
+		config := tgbotapi.NewSetMyCommands(commands...)
+		if lang != i18n.DefaultLocale {
+			config = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), lang, commands...)
+		}
+
+		_, err := b.botApi.Request(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run receives and handles updates forever. If the update loop dies, e.g.
+// because Telegram keeps failing, it is restarted with a growing delay.
 func (b *Bot) Run() {
 	logger.Get().Info("Bot is running")
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	delay := minRestartDelay
+	for {
+		start := time.Now()
+		err := b.pollUpdates()
 
-	updates := b.botApi.GetUpdatesChan(u)
-	for update := range updates {
-		if update.Message == nil {
-			continue
+		if time.Since(start) > stableLoopPeriod {
+			delay = minRestartDelay
 		}
+		logger.Get().Error("Update loop stopped, restarting", zap.Error(err), zap.Duration("delay", delay))
 
-		if update.Message.IsCommand() {
-			switch update.Message.Command() {
-			case "start":
-				err := b.HandleStartCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleStartCmd failed", zap.Error(err))
-				}
-			case "add":
-				err := b.HandleAddCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleAddCmd failed", zap.Error(err))
-				}
-			case "done":
-				err := b.HandleDoneCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleDoneCmd failed", zap.Error(err))
-				}
-			case "current":
-				err := b.HandleCurrentCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleCurrentCmd failed", zap.Error(err))
-				}
-			case "next":
-				err := b.HandleNextCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleNextCmd failed", zap.Error(err))
-				}
-			case "skip":
-				err := b.HandleSkipCmd(update)
-				if err != nil {
-					logger.Get().Error("HandleSkipCmd failed", zap.Error(err))
-				}
-			}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
 		}
 	}
 }
 
-func (b *Bot) HandleStartCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+func (b *Bot) pollUpdates() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("update loop panicked: %v", r)
+		}
+	}()
 
-	_, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
-	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+	failures := 0
+	for {
+		u := tgbotapi.NewUpdate(b.updatesOffset)
+		u.Timeout = pollTimeout
+
+		updates, err := b.botApi.GetUpdates(u)
+		if err != nil {
+			failures++
+			if failures >= maxPollFailures {
+				return err
+			}
+			logger.Get().Warn("Could not get updates", zap.Error(err))
+			time.Sleep(pollRetryDelay)
+			continue
+		}
+		failures = 0
+		b.markPolled(time.Now())
+
+		for _, update := range updates {
+			// The offset is moved before handling, so an update that
+			// crashes the loop is not received again after the restart.
+			if update.UpdateID >= b.updatesOffset {
+				b.updatesOffset = update.UpdateID + 1
+			}
+			b.handleUpdate(update)
 		}
-		return err
 	}
+}
 
-	err = b.SendMessage(update.Message.Chat.ID, "Hello, I'm @read_that_bot!\n"+
-		"I will remind you to read your articles from your reading list(at 5pm UTC, time currently isn't configurable).\n"+
-		"Use /add <article url> command to add new article to your reading list.\n"+
-		"Use /current command to get current article from your reading list.\n"+
-		"Use /done command to mark current article as read.\n"+
-		"Use /next command to get next article from your reading list(if you don't want to wait for the next time I remind you).\n",
-	)
-	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
-		return err
+// SetWebhook points Telegram to url. The webhook config of the library has
+// no secret_token, so the request is made by hand.
+func (b *Bot) SetWebhook(url string) error {
+	params := tgbotapi.Params{
+		"url":          url,
+		"secret_token": b.cfg.Bot.WebhookSecret,
 	}
 
-	return nil
+	_, err := b.botApi.MakeRequest("setWebhook", params)
+	return err
 }
 
-func (b *Bot) HandleAddCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+// DeleteWebhook switches the bot back to polling, Telegram refuses
+// getUpdates while a webhook is set.
+func (b *Bot) DeleteWebhook() error {
+	_, err := b.botApi.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
 
-	user, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
-	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+// WebhookHandler receives updates pushed by Telegram in webhook mode.
+func (b *Bot) WebhookHandler() http.Handler {
+	secret := []byte(b.cfg.Bot.WebhookSecret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := []byte(r.Header.Get(webhookSecretHeader))
+		if subtle.ConstantTimeCompare(token, secret) != 1 {
+			logger.Get().Warn("Webhook request with a wrong secret", zap.String("remote_addr", r.RemoteAddr))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
 		}
-		return err
+
+		update, err := b.botApi.HandleUpdate(r)
+		if err != nil {
+			logger.Get().Warn("Invalid webhook request", zap.Error(err))
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		b.handleUpdate(*update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (b *Bot) markPolled(t time.Time) {
+	b.lastPoll.Store(t.Unix())
+	metrics.LastPoll.Set(float64(t.Unix()))
+}
+
+// LastPoll returns the time of the last successful getUpdates call, or zero
+// time if there was none yet.
+func (b *Bot) LastPoll() time.Time {
+	ts := b.lastPoll.Load()
+	if ts == 0 {
+		return time.Time{}
 	}
+	return time.Unix(ts, 0)
+}
 
-	taskUrl := update.Message.CommandArguments()
-	taskUrl = strings.Trim(taskUrl, " ")
-	if taskUrl == "" {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Please provide article url")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Get().Error("Update handling panicked",
+				zap.Int("update_id", update.UpdateID),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()),
+			)
 		}
-		return errors.New("empty task url")
+	}()
+
+	if update.CallbackQuery != nil {
+		b.handleCallback(update)
+		return
 	}
 
-	task := models.Task{
-		UserId: user.Id,
-		Url:    taskUrl,
-		Status: models.TaskStatusNew,
+	if update.InlineQuery != nil {
+		b.handleInlineQuery(update.InlineQuery)
+		return
 	}
 
-	_, err = b.tasksDao.InsertTask(&task)
-	if err != nil {
-		logger.Get().Error("Could not insert task", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
+	if update.Message == nil {
+		return
+	}
 
-		return err
+	ctx := &Context{
+		Update:  update,
+		Message: update.Message,
+		From:    update.Message.From,
+		ChatId:  update.Message.Chat.ID,
+		Lang:    b.locale(nil, update.Message.From),
+	}
+	if update.Message.IsCommand() {
+		ctx.Command = update.Message.Command()
+		ctx.Args = strings.TrimSpace(update.Message.CommandArguments())
+	} else if b.isTakeawayAnswer(update.Message) {
+		// Plain messages only matter as answers to the bot's questions,
+		// everything else in a chat is none of its business.
+		ctx.Args = strings.TrimSpace(update.Message.Text)
+	} else {
+		return
+	}
+
+	// Errors are already logged and reported to the user by the
+	// middlewares.
+	_ = b.router.Dispatch(ctx)
+}
+
+func (b *Bot) handleCallback(update tgbotapi.Update) {
+	callback := update.CallbackQuery
+
+	// Buttons of inline mode messages have no message to reply to.
+	if callback.Message != nil {
+		name, args := parseCallbackData(callback.Data)
+		ctx := &Context{
+			Update:   update,
+			Message:  callback.Message,
+			Callback: callback,
+			From:     callback.From,
+			ChatId:   callback.Message.Chat.ID,
+			Command:  name,
+			Args:     args,
+			Lang:     b.locale(nil, callback.From),
+		}
+		_ = b.router.Dispatch(ctx)
 	}
 
-	err = b.SendMessage(update.Message.Chat.ID, "Task added successfully")
+	// Telegram shows a spinner on the button until the query is answered.
+	_, err := b.botApi.Request(tgbotapi.NewCallback(callback.ID, ""))
 	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
-		return err
+		logger.Get().Error("Could not answer callback query", zap.Error(err))
 	}
-	return nil
 }
 
-func (b *Bot) HandleDoneCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+// HandleStartCmd greets the user. Links to the bot pass a payload to
+// /start, which runs the action of the link instead.
+func (b *Bot) HandleStartCmd(ctx *Context) error {
+	if ctx.Args != "" && ctx.Args != inlineStartParameter {
+		return b.handleStartPayload(ctx)
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "start.greeting")+"\n\n"+b.helpText(ctx.Lang))
+}
+
+func (b *Bot) HandleHelpCmd(ctx *Context) error {
+	return b.SendMessage(ctx.ChatId, b.helpText(ctx.Lang))
+}
+
+func (b *Bot) HandleAddCmd(ctx *Context) error {
+	taskUrl, tags := parseAddArgs(ctx.Args)
+	if taskUrl == "" {
+		return errs.NewErrUser("add.empty_url", nil, nil)
+	}
 
-	user, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
+	_, err := b.AddTask(ctx.User.Id, taskUrl, tags)
 	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
 		return err
 	}
 
-	tasks, err := b.tasksDao.GetInProgressTasksByUserId(user.Id)
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "add.success"))
+}
+
+// AddTask puts a new article into the user's list and saves its snapshot
+// in the background.
+func (b *Bot) AddTask(userId int64, taskUrl string, tags []string) (*models.Task, error) {
+	err := checkUrlLength(taskUrl)
 	if err != nil {
-		logger.Get().Error("Could not get tasks", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
+		return nil, err
+	}
+
+	task := models.Task{
+		UserId: userId,
+		Url:    taskUrl,
+		Status: models.TaskStatusNew,
+		Tags:   tags,
+	}
+
+	newTask, err := b.tasksDao.InsertTask(&task)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.snapshots != nil {
+		b.queueSnapshot(newTask)
+	}
+
+	return newTask, nil
+}
 
+// checkUrlLength refuses URLs longer than the url columns.
+func checkUrlLength(taskUrl string) error {
+	if utf8.RuneCountInString(taskUrl) > models.MaxUrlLength {
+		return errs.NewErrUser("add.url_too_long", i18n.Data{"Max": models.MaxUrlLength}, nil)
+	}
+
+	return nil
+}
+
+func (b *Bot) HandleDoneCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
+	if err != nil {
 		return err
 	}
 
 	if len(tasks) == 0 {
-		err = b.SendMessage(update.Message.Chat.ID, "You don't have any tasks in progress")
-		if err != nil {
-			logger.Get().Error("Could not send message", zap.Error(err))
-			return err
-		}
-		return nil
+		return errs.NewErrUser("tasks.none_in_progress", nil, nil)
 	}
 
 	var taskIds []int64
@@ -199,283 +494,414 @@ func (b *Bot) HandleDoneCmd(update tgbotapi.Update) error {
 
 	err = b.tasksDao.UpdateTasksStatus(taskIds, models.TaskStatusDone)
 	if err != nil {
-		logger.Get().Error("Could not update tasks", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
-
 		return err
 	}
 
-	tasks, err = b.tasksDao.GetUsersTasksByStatus(user.Id, models.TaskStatusNew)
+	left, err := b.tasksDao.CountUsersTasksByStatus(ctx.User.Id, models.TaskStatusNew)
 	if err != nil {
-		logger.Get().Error("Could not get tasks", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
+		return err
+	}
 
+	msg := tgbotapi.NewMessage(ctx.ChatId, b.plural(ctx.Lang, "done.success", left))
+	msg.ReplyMarkup = rateKeyboard(tasks[0].Id)
+	_, err = b.botApi.Send(msg)
+	if err != nil {
 		return err
 	}
 
-	err = b.SendMessage(update.Message.Chat.ID, fmt.Sprintf("Tasks marked as done successfully. You got %d task(s) left in backlog", len(tasks)))
+	return b.promptTakeaway(ctx, tasks[0])
+}
+
+func (b *Bot) HandleCurrentCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetUsersTasksByStatus(ctx.User.Id, models.TaskStatusInProgress)
 	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
 		return err
 	}
 
-	return nil
+	if len(tasks) == 0 {
+		return errs.NewErrUser("tasks.none_in_progress", nil, nil)
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "current.task", i18n.Data{"Url": tasks[0].Url}))
 }
 
-func (b *Bot) HandleCurrentCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+func (b *Bot) HandleNextCmd(ctx *Context) error {
+	return b.replyNextTask(ctx)
+}
 
-	user, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
+func (b *Bot) HandleSkipCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
 	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+		return err
+	}
+
+	if len(tasks) > 0 {
+		var taskIds []int64
+		for _, task := range tasks {
+			taskIds = append(taskIds, task.Id)
 		}
+
+		err = b.tasksDao.UpdateTasksStatus(taskIds, models.TaskStatusNew)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.replyNextTask(ctx)
+}
+
+func (b *Bot) replyNextTask(ctx *Context) error {
+	task, err := b.GetNextTask(ctx.User)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return errs.NewErrUser("next.not_found", nil, err)
+	}
+	// ErrNotFinished is turned into a reply with the current task by the
+	// ErrorReply middleware.
+	if err != nil {
 		return err
 	}
 
-	tasks, err := b.tasksDao.GetUsersTasksByStatus(user.Id, models.TaskStatusInProgress)
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "next.task", i18n.Data{"Url": task.Url}))
+}
+
+func (b *Bot) GetNextTask(user *models.User) (*models.Task, error) {
+	inProgressTasks, err := b.tasksDao.GetUsersTasksByStatus(user.Id, models.TaskStatusInProgress)
 	if err != nil {
 		logger.Get().Error("Could not get tasks", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+		return nil, err
+	}
+
+	if len(inProgressTasks) > 0 {
+		return nil, errs.NewErrNotFinished(inProgressTasks[0])
+	}
+
+	task, err := b.pickNextTask(user)
+	if err != nil {
+		if errors.Is(err, &errs.ErrNotFound{}) {
+			return nil, err
 		}
+		logger.Get().Error("Could not get random task", zap.Error(err))
+		return nil, err
+	}
+
+	err = b.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusInProgress)
+	if err != nil {
+		logger.Get().Error("Could not update task status", zap.Error(err))
+		return nil, err
+	}
 
+	return task, nil
+}
+
+func (b *Bot) HandleScheduleCmd(ctx *Context) error {
+	if ctx.Args == "" {
+		return b.SendMessage(ctx.ChatId, b.plural(ctx.Lang, "schedule.current", ctx.User.ReminderCount, i18n.Data{
+			"Schedule": reminders.PresetName(ctx.User.ReminderSchedule),
+			"Presets":  strings.Join(reminders.PresetNames(), ", "),
+		}))
+	}
+
+	schedule, count, err := parseScheduleArgs(ctx.Args)
+	if err != nil {
+		return errs.NewErrUser("schedule.invalid", map[string]any{
+			"Presets":  strings.Join(reminders.PresetNames(), ", "),
+			"MaxCount": reminders.MaxCount,
+		}, err)
+	}
+
+	err = b.usersDao.UpdateUserReminderSchedule(ctx.User.Id, schedule, count)
+	if err != nil {
 		return err
 	}
 
-	if len(tasks) == 0 {
-		err = b.SendMessage(update.Message.Chat.ID, "You don't have any tasks in progress")
-		if err != nil {
-			logger.Get().Error("Could not send message", zap.Error(err))
-			return err
+	return b.SendMessage(ctx.ChatId, b.plural(ctx.Lang, "schedule.updated", count, i18n.Data{
+		"Schedule": reminders.PresetName(schedule),
+	}))
+}
+
+func (b *Bot) HandleDigestCmd(ctx *Context) error {
+	var enabled bool
+	switch strings.ToLower(ctx.Args) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		key := "digest.status_off"
+		if ctx.User.DigestEnabled {
+			key = "digest.status_on"
 		}
-		return nil
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, key))
 	}
 
-	task := tasks[0]
-	err = b.SendMessage(update.Message.Chat.ID, fmt.Sprintf("Your current task is %s", task.Url))
+	err := b.usersDao.UpdateUserDigestEnabled(ctx.User.Id, enabled)
 	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
 		return err
 	}
 
-	return nil
+	key := "digest.disabled"
+	if enabled {
+		key = "digest.enabled"
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, key))
 }
 
-func (b *Bot) HandleNextCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+func (b *Bot) HandleLangCmd(ctx *Context) error {
+	locales := strings.Join(b.catalog.Locales(), ", ")
+
+	newLang := strings.ToLower(ctx.Args)
+	if newLang == "" {
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "lang.current", i18n.Data{"Lang": ctx.Lang, "Locales": locales}))
+	}
+
+	// "auto" removes the override, so the language of the Telegram client
+	// is used again.
+	if newLang == "auto" {
+		newLang = ""
+	} else if !b.catalog.Has(newLang) {
+		return errs.NewErrUser("lang.invalid", map[string]any{"Locales": locales}, nil)
+	}
 
-	user, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
+	err := b.usersDao.UpdateUserLanguage(ctx.User.Id, newLang)
 	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
 		return err
 	}
 
-	task, err := b.GetNextTask(user)
+	ctx.User.Language = newLang
+	ctx.Lang = b.locale(ctx.User, ctx.From)
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "lang.updated", i18n.Data{"Lang": ctx.Lang}))
+}
+
+func (b *Bot) SendDigests() error {
+	users, err := b.usersDao.GetAllUsers()
 	if err != nil {
-		if errors.Is(err, &errs.ErrNotFinished{}) {
-			var notFinishedErr *errs.ErrNotFinished
-			errors.As(err, &notFinishedErr)
-			sendErr := b.SendMessage(update.Message.Chat.ID, "You have unfinished task. Please finish it first. Your current task is \n"+notFinishedErr.Task.Url)
-			if sendErr != nil {
-				logger.Get().Error("Could not send message", zap.Error(sendErr))
-				return err
-			}
-			return sendErr
-		}
+		logger.Get().Error("Could not get users", zap.Error(err))
+		return err
+	}
 
-		if errors.Is(err, &errs.ErrNotFound{}) {
-			sendErr := b.SendMessage(update.Message.Chat.ID, "There is no tasks available. Please add some tasks first")
-			if sendErr != nil {
-				logger.Get().Error("Could not send message", zap.Error(sendErr))
-				return sendErr
-			}
-			return sendErr
+	now := time.Now()
+	for _, user := range users {
+		if !user.DigestEnabled || user.Banned {
+			continue
 		}
 
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+		err = b.SendUserDigest(user, now)
+		if err != nil {
+			logger.Get().Error("Could not send digest", zap.Int64("user_id", user.Id), zap.Error(err))
 		}
+	}
 
+	return nil
+}
+
+func (b *Bot) SendUserDigest(user *models.User, now time.Time) error {
+	d, err := b.digests.Generate(user, now)
+	if err != nil {
 		return err
 	}
 
-	err = b.SendMessage(update.Message.Chat.ID, fmt.Sprintf("Your next task is: \n%s", task.Url))
+	if d.IsEmpty() {
+		return nil
+	}
+
+	err = b.reminderLimiter.Wait(context.Background())
 	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
 		return err
 	}
 
-	return nil
-}
+	err = b.SendHTMLMessage(user.ChatId, digest.Render(d, b.catalog, b.locale(user, nil)))
+	metrics.ObserveReminder(err)
 
-func (b *Bot) HandleSkipCmd(update tgbotapi.Update) error {
-	inputTgUserId := update.Message.From.ID
-	tgUserId := strconv.FormatInt(inputTgUserId, 10)
+	return err
+}
 
-	user, err := b.ensureUserExists(tgUserId, update.Message.Chat.ID)
+func (b *Bot) SendReminders() error {
+	users, err := b.usersDao.GetAllUsers()
 	if err != nil {
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
-		}
+		logger.Get().Error("Could not get users", zap.Error(err))
 		return err
 	}
 
-	tasks, err := b.tasksDao.GetInProgressTasksByUserId(user.Id)
-	if err != nil {
-		logger.Get().Error("Could not get tasks", zap.Error(err))
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-		if sendErr != nil {
-			logger.Get().Error("Could not send message", zap.Error(sendErr))
+	for _, user := range users {
+		err = b.SendUserReminder(user)
+		if err != nil {
+			logger.Get().Error("Could not send reminder", zap.Int64("user_id", user.Id), zap.Error(err))
 		}
+	}
 
-		return err
+	return nil
+}
+
+// Broadcast sends the text to every user. Messages share the reminder rate
+// limit, so a broadcast never pushes the bot over the Telegram limits.
+func (b *Bot) Broadcast(text string) (sent int, failed int, err error) {
+	users, err := b.usersDao.GetAllUsers()
+	if err != nil {
+		return 0, 0, err
 	}
 
-	if len(tasks) >= 0 {
-		var taskIds []int64
-		for _, task := range tasks {
-			taskIds = append(taskIds, task.Id)
+	for _, user := range users {
+		if user.Banned {
+			continue
 		}
 
-		err = b.tasksDao.UpdateTasksStatus(taskIds, models.TaskStatusNew)
+		err = b.reminderLimiter.Wait(context.Background())
 		if err != nil {
-			logger.Get().Error("Could not update tasks", zap.Error(err))
-			sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
-			if sendErr != nil {
-				logger.Get().Error("Could not send message", zap.Error(sendErr))
-			}
+			return sent, failed, err
+		}
 
-			return err
+		err = b.SendMessage(user.ChatId, text)
+		if err != nil {
+			logger.Get().Error("Could not send broadcast", zap.Int64("user_id", user.Id), zap.Error(err))
+			failed++
+			continue
 		}
+		sent++
+	}
+
+	return sent, failed, nil
+}
+
+func (b *Bot) SendUserReminder(user *models.User) error {
+	if user.Banned {
+		return nil
+	}
+
+	err := b.reminderLimiter.Wait(context.Background())
+	if err != nil {
+		return err
 	}
 
+	if user.ReminderCount > 1 {
+		return b.sendReminderDigest(user)
+	}
+
+	lang := b.locale(user, nil)
+
 	task, err := b.GetNextTask(user)
 	if err != nil {
 		if errors.Is(err, &errs.ErrNotFinished{}) {
 			var notFinishedErr *errs.ErrNotFinished
 			errors.As(err, &notFinishedErr)
-			sendErr := b.SendMessage(update.Message.Chat.ID, "You have unfinished task. Please finish it first. Your current task is \n"+notFinishedErr.Task.Url)
-			if sendErr != nil {
-				logger.Get().Error("Could not send message", zap.Error(sendErr))
-				return err
-			}
-			return sendErr
+			return b.sendReminderMessage(user.ChatId, b.t(lang, "next.unfinished", i18n.Data{"Url": notFinishedErr.Task.Url}))
 		}
 
 		if errors.Is(err, &errs.ErrNotFound{}) {
-			sendErr := b.SendMessage(update.Message.Chat.ID, "There is no tasks available. Please add some tasks first")
-			if sendErr != nil {
-				logger.Get().Error("Could not send message", zap.Error(sendErr))
-				return sendErr
-			}
-			return sendErr
+			return nil
 		}
 
-		sendErr := b.SendMessage(update.Message.Chat.ID, "Something went wrong, please try again later")
+		metrics.ObserveReminder(err)
+		sendErr := b.SendMessage(user.ChatId, b.t(lang, "error.generic"))
 		if sendErr != nil {
 			logger.Get().Error("Could not send message", zap.Error(sendErr))
 		}
-
 		return err
 	}
 
-	err = b.SendMessage(update.Message.Chat.ID, fmt.Sprintf("Your next task is: \n%s", task.Url))
+	return b.sendReminderMessage(user.ChatId, b.t(lang, "next.task", i18n.Data{"Url": task.Url}))
+}
+
+func (b *Bot) sendReminderDigest(user *models.User) error {
+	tasks, err := b.tasksDao.GetUsersRandomTasksByStatus(user.Id, models.TaskStatusNew, uint64(user.ReminderCount))
 	if err != nil {
-		logger.Get().Error("Could not send message", zap.Error(err))
 		return err
 	}
 
-	return nil
-}
-
-func (b *Bot) GetNextTask(user *models.User) (*models.Task, error) {
-	inProgressTasks, err := b.tasksDao.GetUsersTasksByStatus(user.Id, models.TaskStatusInProgress)
-	if err != nil {
-		logger.Get().Error("Could not get tasks", zap.Error(err))
-		return nil, err
+	if len(tasks) == 0 {
+		return nil
 	}
 
-	if len(inProgressTasks) > 0 {
-		return nil, errs.NewErrNotFinished(inProgressTasks[0])
+	var text strings.Builder
+	text.WriteString(b.plural(b.locale(user, nil), "reminder.digest", len(tasks)))
+	text.WriteString("\n")
+	for i, task := range tasks {
+		text.WriteString(fmt.Sprintf("%d. %s\n", i+1, task.Url))
 	}
 
-	task, err := b.tasksDao.GetUsersRandomTaskByStatus(user.Id, models.TaskStatusNew)
-	if err != nil {
-		if errors.Is(err, &errs.ErrNotFound{}) {
-			return nil, err
-		}
-		logger.Get().Error("Could not get random task", zap.Error(err))
-		return nil, err
+	return b.sendReminderMessage(user.ChatId, text.String())
+}
+
+func (b *Bot) sendReminderMessage(chatId int64, text string) error {
+	err := b.SendMessage(chatId, text)
+	metrics.ObserveReminder(err)
+
+	return err
+}
+
+// replyError sends the reply for an error returned by a handler. Unexpected
+// errors get a reference ID, which is returned with the error so it ends up
+// in the log.
+func (b *Bot) replyError(chatId int64, lang string, err error) error {
+	var text string
+	if userErr, ok := errs.ToUser(err); ok {
+		text = b.t(lang, userErr.Key, i18n.Data(userErr.Data))
+	} else {
+		refErr := errs.NewErrWithRef(err)
+		text = b.t(lang, "error.generic_ref", i18n.Data{"Ref": refErr.Ref})
+		err = refErr
 	}
 
-	err = b.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusInProgress)
-	if err != nil {
-		logger.Get().Error("Could not update task status", zap.Error(err))
-		return nil, err
+	sendErr := b.SendMessage(chatId, text)
+	if sendErr != nil {
+		logger.Get().Error("Could not send message", zap.Error(sendErr))
 	}
 
-	return task, nil
+	return err
 }
 
-func (b *Bot) SendReminders() error {
-	users, err := b.usersDao.GetAllUsers()
+func (b *Bot) SendMessage(chatId int64, text string) error {
+	msg := tgbotapi.NewMessage(chatId, text)
+	_, err := b.botApi.Send(msg)
 	if err != nil {
-		logger.Get().Error("Could not get users", zap.Error(err))
 		return err
 	}
 
-	for _, user := range users {
-		task, err := b.GetNextTask(user)
-		if err != nil {
-			if errors.Is(err, &errs.ErrNotFinished{}) {
-				var notFinishedErr *errs.ErrNotFinished
-				errors.As(err, &notFinishedErr)
-				err = b.SendMessage(user.ChatId, "You have unfinished task. Please finish it first. Your current task is \n"+notFinishedErr.Task.Url)
-				if err != nil {
-					logger.Get().Error("Could not send message", zap.Error(err))
-				}
-				continue
-			}
+	return nil
+}
 
-			if errors.Is(err, &errs.ErrNotFound{}) {
-				continue
-			}
+// messageLength is the length of text the way Telegram counts it.
+func messageLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += runeLength(r)
+	}
 
-			err = b.SendMessage(user.ChatId, "Something went wrong, please try again later")
-			if err != nil {
-				logger.Get().Error("Could not send message", zap.Error(err))
-			}
-			continue
-		}
+	return n
+}
 
-		err = b.SendMessage(user.ChatId, fmt.Sprintf("Your next task is: \n%s", task.Url))
-		if err != nil {
-			logger.Get().Error("Could not send message", zap.Error(err))
-			continue
+// cutMessage shortens text to at most max code units, ending it with "…".
+func cutMessage(text string, max int) string {
+	if messageLength(text) <= max {
+		return text
+	}
+
+	n := 1
+	for i, r := range text {
+		n += runeLength(r)
+		if n > max {
+			return text[:i] + "…"
 		}
 	}
 
-	return nil
+	return text
 }
 
-func (b *Bot) SendMessage(chatId int64, text string) error {
+// runeLength is 2 for runes outside the Basic Multilingual Plane, like most
+// emoji, which take a surrogate pair in UTF-16.
+func runeLength(r rune) int {
+	if r < 0x10000 {
+		return 1
+	}
+
+	return 2
+}
+
+func (b *Bot) SendHTMLMessage(chatId int64, text string) error {
 	msg := tgbotapi.NewMessage(chatId, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
 	_, err := b.botApi.Send(msg)
 	if err != nil {
 		return err
@@ -484,14 +910,50 @@ func (b *Bot) SendMessage(chatId int64, text string) error {
 	return nil
 }
 
+// helpText lists the registered commands with their descriptions.
+func (b *Bot) helpText(lang string) string {
+	var text strings.Builder
+	text.WriteString(b.t(lang, "help.title"))
+	text.WriteString("\n")
+	for _, cmd := range b.router.Commands() {
+		text.WriteString(fmt.Sprintf("/%s - %s\n", cmd.Name, b.t(lang, cmd.Description)))
+	}
+
+	return text.String()
+}
+
+// locale picks the language of a reply: the user's /lang override if set,
+// otherwise the language of their Telegram client.
+func (b *Bot) locale(user *models.User, from *tgbotapi.User) string {
+	if user != nil && user.Language != "" && b.catalog.Has(user.Language) {
+		return user.Language
+	}
+
+	if from != nil {
+		return b.catalog.Match(from.LanguageCode)
+	}
+
+	return i18n.DefaultLocale
+}
+
+func (b *Bot) t(lang, key string, data ...i18n.Data) string {
+	return b.catalog.T(lang, key, data...)
+}
+
+func (b *Bot) plural(lang, key string, count int, data ...i18n.Data) string {
+	return b.catalog.Plural(lang, key, count, data...)
+}
+
 func (b *Bot) ensureUserExists(tgUserExternalId string, chatId int64) (*models.User, error) {
 	var user *models.User
 	user, err := b.usersDao.GetUserByExternalId(tgUserExternalId)
 	if err != nil {
 		if errors.Is(err, &errs.ErrNotFound{}) {
 			user, err = b.usersDao.InsertUser(&models.User{
-				ExternalId: tgUserExternalId,
-				ChatId:     chatId,
+				ExternalId:       tgUserExternalId,
+				ChatId:           chatId,
+				ReminderSchedule: b.cfg.Reminders.DefaultSchedule,
+				ReminderCount:    b.cfg.Reminders.DefaultCount,
 			})
 			if err != nil {
 				logger.Get().Error("Could not insert user", zap.Error(err))
@@ -505,3 +967,48 @@ func (b *Bot) ensureUserExists(tgUserExternalId string, chatId int64) (*models.U
 
 	return user, nil
 }
+
+// parseAddArgs splits "<url> [#tag...]" into the URL and the tags.
+func parseAddArgs(args string) (string, []string) {
+	var words, tags []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(args) {
+		if len(word) > 1 && word[0] == '#' {
+			tag := strings.ToLower(word[1:])
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		words = append(words, word)
+	}
+
+	return strings.Join(words, " "), tags
+}
+
+// parseScheduleArgs parses "<preset|cron> [count]". The trailing number is
+// only treated as a count if the rest is a valid schedule, so cron
+// expressions ending with a number still work on their own.
+func parseScheduleArgs(args string) (string, int, error) {
+	fields := strings.Fields(args)
+	if len(fields) > 1 {
+		count, err := strconv.Atoi(fields[len(fields)-1])
+		if err == nil {
+			schedule, err := reminders.ParseSchedule(strings.Join(fields[:len(fields)-1], " "))
+			if err == nil {
+				if count < 1 || count > reminders.MaxCount {
+					return "", 0, fmt.Errorf("count %d is out of range", count)
+				}
+				return schedule, count, nil
+			}
+		}
+	}
+
+	schedule, err := reminders.ParseSchedule(args)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return schedule, 1, nil
+}
@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+)
+
+// URLs longer than the url columns are refused before anything is stored,
+// the bot has no DAOs.
+func TestLongUrlsAreRefused(t *testing.T) {
+	b := &Bot{}
+	longUrl := "https://example.com/" + strings.Repeat("a", models.MaxUrlLength)
+
+	_, err := b.AddTask(1, longUrl, nil)
+	if userErr, ok := errs.ToUser(err); !ok || userErr.Key != "add.url_too_long" {
+		t.Errorf("add: got %v, want add.url_too_long", err)
+	}
+
+	for _, args := range []string{longUrl, longUrl + " @alice", longUrl + " @team"} {
+		ctx := &Context{Command: "share", Args: args, User: &models.User{Id: 1}}
+		err := b.HandleShareCmd(ctx)
+		if userErr, ok := errs.ToUser(err); !ok || userErr.Key != "add.url_too_long" {
+			t.Errorf("share %q: got %v, want add.url_too_long", args[len(longUrl):], err)
+		}
+	}
+}
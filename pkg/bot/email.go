@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"tg_bot/pkg/i18n"
+)
+
+const (
+	emailResetArg   = "reset"
+	inboxTokenBytes = 12
+)
+
+// HandleEmailCmd shows the address that adds mailed links to the user's
+// list, "/email reset" replaces it with a new one.
+func (b *Bot) HandleEmailCmd(ctx *Context) error {
+	token := ctx.User.InboxToken
+	if token == "" || ctx.Args == emailResetArg {
+		raw := make([]byte, inboxTokenBytes)
+		_, err := rand.Read(raw)
+		if err != nil {
+			return err
+		}
+		token = hex.EncodeToString(raw)
+
+		err = b.usersDao.UpdateUserInboxToken(ctx.User.Id, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "email.address", i18n.Data{"Address": token + "@" + b.cfg.SMTP.Domain}))
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"net/url"
+	"strconv"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/feeds"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// feedPollBatch is the number of feeds checked every 5 minutes.
+	feedPollBatch = 100
+	// feedFailingAfter is the number of failed checks in a row after which
+	// /feeds flags a feed.
+	feedFailingAfter = 3
+)
+
+// HandleSubscribeCmd subscribes the user to an RSS or Atom feed. Its
+// newest entries are added right away, later ones as they come out. The
+// feed is downloaded in the background, so a slow site doesn't hold up
+// other updates, and the user gets the outcome in another message.
+func (b *Bot) HandleSubscribeCmd(ctx *Context) error {
+	feedUrl := strings.TrimSpace(ctx.Args)
+	if feedUrl == "" {
+		return errs.NewErrUser("feeds.empty_url", nil, nil)
+	}
+
+	u, err := url.Parse(feedUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || utf8.RuneCountInString(feedUrl) > models.MaxUrlLength {
+		return errs.NewErrUser("feeds.invalid", nil, err)
+	}
+
+	subscribed, err := b.feedsDao.GetUsersFeeds(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+	for _, feed := range subscribed {
+		if feed.Url == feedUrl {
+			return errs.NewErrUser("feeds.already", nil, nil)
+		}
+	}
+	if len(subscribed) >= b.cfg.Feeds.MaxPerUser {
+		return errs.NewErrUser("feeds.limit", i18n.Data{"Max": b.cfg.Feeds.MaxPerUser}, nil)
+	}
+
+	err = b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "feeds.checking"))
+	if err != nil {
+		return err
+	}
+
+	go b.subscribe(ctx.ChatId, ctx.Lang, ctx.User.Id, feedUrl)
+
+	return nil
+}
+
+func (b *Bot) subscribe(chatId int64, lang string, userId int64, feedUrl string) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Feeds.Timeout)
+	defer cancel()
+
+	feed, added, err := b.feeds.Subscribe(ctx, userId, feedUrl, time.Now())
+	if errors.Is(err, feeds.ErrNotFeed) {
+		err = errs.NewErrUser("feeds.invalid", nil, err)
+	} else if errors.Is(err, feeds.ErrUnavailable) {
+		err = errs.NewErrUser("feeds.unavailable", nil, err)
+	}
+	if err != nil {
+		err = b.replyError(chatId, lang, err)
+		if _, ok := errs.ToUser(err); !ok {
+			logger.Get().Error("Could not subscribe to feed", zap.Int64("user_id", userId), zap.String("url", feedUrl), zap.String("error_ref", errs.RefOf(err)), zap.Error(err))
+		}
+		return
+	}
+
+	err = b.SendMessage(chatId, b.plural(lang, "feeds.subscribed", added, i18n.Data{"Title": feedTitle(feed)}))
+	if err != nil {
+		logger.Get().Error("Could not send message", zap.Error(err))
+	}
+}
+
+// HandleFeedsCmd lists the user's feeds, numbered for /unsubscribe.
+func (b *Bot) HandleFeedsCmd(ctx *Context) error {
+	subscribed, err := b.feedsDao.GetUsersFeeds(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	if len(subscribed) == 0 {
+		return errs.NewErrUser("feeds.none", nil, nil)
+	}
+
+	var text strings.Builder
+	text.WriteString(b.t(ctx.Lang, "feeds.title"))
+	text.WriteString("\n")
+	for i, feed := range subscribed {
+		text.WriteString(fmt.Sprintf("%d. %s\n", i+1, feedTitle(feed)))
+		if feed.Title != "" {
+			text.WriteString(feed.Url)
+			text.WriteString("\n")
+		}
+		if feed.Failures >= feedFailingAfter {
+			text.WriteString(b.t(ctx.Lang, "feeds.failing"))
+			text.WriteString("\n")
+		}
+	}
+	text.WriteString("\n")
+	text.WriteString(b.t(ctx.Lang, "feeds.unsubscribe_hint"))
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, text.String())
+	msg.DisableWebPagePreview = true
+	_, err = b.botApi.Send(msg)
+
+	return err
+}
+
+// HandleUnsubscribeCmd removes a feed by its number in /feeds or its URL.
+// The articles already added stay in the list.
+func (b *Bot) HandleUnsubscribeCmd(ctx *Context) error {
+	arg := strings.TrimSpace(ctx.Args)
+	if arg == "" {
+		return errs.NewErrUser("feeds.unsubscribe_usage", nil, nil)
+	}
+
+	subscribed, err := b.feedsDao.GetUsersFeeds(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	var feed *models.Feed
+	if number, err := strconv.Atoi(arg); err == nil {
+		if number >= 1 && number <= len(subscribed) {
+			feed = subscribed[number-1]
+		}
+	} else {
+		for _, f := range subscribed {
+			if f.Url == arg {
+				feed = f
+				break
+			}
+		}
+	}
+	if feed == nil {
+		return errs.NewErrUser("feeds.not_found", nil, nil)
+	}
+
+	_, err = b.feedsDao.DeleteUsersFeed(ctx.User.Id, feed.Id)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "feeds.unsubscribed", i18n.Data{"Title": feedTitle(feed)}))
+}
+
+// PollFeeds adds the new entries of one batch of due feeds and returns how
+// many articles were added.
+func (b *Bot) PollFeeds(now time.Time) (int, error) {
+	if b.feeds == nil {
+		return 0, nil
+	}
+
+	return b.feeds.Run(context.Background(), now)
+}
+
+func feedTitle(feed *models.Feed) string {
+	if feed.Title != "" {
+		return feed.Title
+	}
+
+	return feed.Url
+}
@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/ratings"
+	"time"
+)
+
+const (
+	// Telegram shows at most 50 results per answer.
+	inlinePageSize = 20
+	// inlineCacheTime is short since the list changes with every /add.
+	inlineCacheTime = 10
+	// inlineStartParameter is the /start payload sent when someone without
+	// a reading list taps the button above the results.
+	inlineStartParameter = "inline"
+)
+
+// handleInlineQuery answers "@bot <query>" typed in any chat with the
+// sender's saved articles matching the query, or with the latest unread ones
+// if the query is empty. The chosen result is sent to the chat as a link.
+//
+// Inline queries don't belong to a chat, so nothing can be replied to them
+// and they bypass the router. Users are never created here. Inline mode has
+// to be turned on for the bot with @BotFather /setinline.
+func (b *Bot) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	start := time.Now()
+	result := metrics.ResultOk
+	defer func() {
+		metrics.UpdatesProcessed.WithLabelValues("inline_query", result).Inc()
+		metrics.HandlerDuration.WithLabelValues("inline_query").Observe(time.Since(start).Seconds())
+	}()
+
+	answer, err := b.inlineAnswer(query)
+	if err != nil {
+		result = metrics.ResultError
+		logger.Get().Error("Could not answer inline query", zap.Int64("from", query.From.ID), zap.Error(err))
+		// An empty answer stops the client from waiting for results.
+		answer = tgbotapi.InlineConfig{InlineQueryID: query.ID, IsPersonal: true, Results: []interface{}{}}
+	}
+
+	_, err = b.botApi.Request(answer)
+	if err != nil {
+		result = metrics.ResultError
+		logger.Get().Error("Could not send inline query answer", zap.Int64("from", query.From.ID), zap.Error(err))
+	}
+}
+
+func (b *Bot) inlineAnswer(query *tgbotapi.InlineQuery) (tgbotapi.InlineConfig, error) {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		CacheTime:     inlineCacheTime,
+		// Results are the sender's own articles.
+		IsPersonal: true,
+	}
+
+	user, err := b.usersDao.GetUserByExternalId(strconv.FormatInt(query.From.ID, 10))
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		answer.SwitchPMText = b.t(b.locale(nil, query.From), "inline.start")
+		answer.SwitchPMParameter = inlineStartParameter
+		return answer, nil
+	}
+	if err != nil {
+		return answer, err
+	}
+
+	if user.Banned {
+		return answer, nil
+	}
+
+	// The offset is the one we set as NextOffset for the previous page.
+	offset, err := strconv.ParseUint(query.Offset, 10, 64)
+	if err != nil {
+		offset = 0
+	}
+
+	text := strings.TrimSpace(query.Query)
+	var tasks []*models.Task
+	if text == "" {
+		if offset > 0 {
+			return answer, nil
+		}
+		tasks, err = b.tasksDao.GetUsersRecentTasksByStatus(user.Id, models.TaskStatusNew, inlinePageSize)
+	} else {
+		tasks, err = b.tasksDao.SearchUsersTasks(user.Id, text, offset, inlinePageSize)
+	}
+	if err != nil {
+		return answer, err
+	}
+
+	for _, task := range tasks {
+		answer.Results = append(answer.Results, inlineResult(task))
+	}
+	if text != "" && len(tasks) == inlinePageSize {
+		answer.NextOffset = strconv.FormatUint(offset+inlinePageSize, 10)
+	}
+
+	return answer, nil
+}
+
+// inlineResult shares the bare URL, so Telegram renders the usual link
+// preview card in the chat.
+func inlineResult(task *models.Task) tgbotapi.InlineQueryResultArticle {
+	title := ratings.Domain(task.Url)
+	if title == "" {
+		title = task.Url
+	}
+
+	description := task.Url
+	if len(task.Tags) > 0 {
+		description += "\n#" + strings.Join(task.Tags, " #")
+	}
+
+	result := tgbotapi.NewInlineQueryResultArticle(strconv.FormatInt(task.Id, 10), title, task.Url)
+	result.Description = description
+	result.URL = task.Url
+
+	return result
+}
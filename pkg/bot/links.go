@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"tg_bot/logger"
+	"tg_bot/pkg/deeplink"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"time"
+)
+
+const (
+	// referralWindow is how soon after their first contact a user can still
+	// be counted as invited.
+	referralWindow = 24 * time.Hour
+
+	teamLeaveArg = "leave"
+)
+
+// startLink returns a signed t.me link that runs the action when opened.
+func (b *Bot) startLink(action deeplink.Action, arg []byte) (string, error) {
+	if b.links == nil {
+		return "", errs.NewErrUser("links.disabled", nil, nil)
+	}
+
+	payload, err := b.links.Sign(deeplink.Payload{
+		Action:    action,
+		Arg:       arg,
+		ExpiresAt: time.Now().Add(b.cfg.Links.TTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.botApi.Self.UserName, payload), nil
+}
+
+// handleStartPayload runs the action of a signed link. Each user can use a
+// link once. The use is claimed before the action, so opening a link twice
+// at once doesn't run it twice, and released again if the action fails.
+func (b *Bot) handleStartPayload(ctx *Context) error {
+	if b.links == nil {
+		return errs.NewErrUser("links.invalid", nil, nil)
+	}
+
+	payload, err := b.links.Verify(ctx.Args, time.Now())
+	if errors.Is(err, deeplink.ErrExpired) {
+		return errs.NewErrUser("links.expired", nil, err)
+	}
+	if err != nil {
+		return errs.NewErrUser("links.invalid", nil, err)
+	}
+
+	unused, err := b.linksDao.UseLink(payload.Id, ctx.User.Id, payload.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return errs.NewErrUser("links.used", nil, nil)
+	}
+
+	err = b.runLinkAction(ctx, payload)
+	if err != nil {
+		releaseErr := b.linksDao.ReleaseLink(payload.Id, ctx.User.Id)
+		if releaseErr != nil {
+			logger.Get().Error("Could not release link", zap.String("link_id", payload.Id), zap.Error(releaseErr))
+		}
+	}
+
+	return err
+}
+
+func (b *Bot) runLinkAction(ctx *Context, payload *deeplink.Payload) error {
+	switch payload.Action {
+	case deeplink.ActionAddArticle:
+		return b.acceptShare(ctx, base64.RawURLEncoding.EncodeToString(payload.Arg))
+	case deeplink.ActionJoinTeam:
+		ownerId, err := payload.Int64()
+		if err != nil {
+			return errs.NewErrUser("links.invalid", nil, err)
+		}
+		return b.joinTeam(ctx, ownerId)
+	case deeplink.ActionReferral:
+		referrerId, err := payload.Int64()
+		if err != nil {
+			return errs.NewErrUser("links.invalid", nil, err)
+		}
+		return b.acceptReferral(ctx, referrerId)
+	default:
+		return errs.NewErrUser("links.invalid", nil, nil)
+	}
+}
+
+// HandleInviteCmd gives the user a link to invite others to the bot.
+func (b *Bot) HandleInviteCmd(ctx *Context) error {
+	link, err := b.startLink(deeplink.ActionReferral, deeplink.Int64Arg(ctx.User.Id))
+	if err != nil {
+		return err
+	}
+
+	invited, err := b.usersDao.CountUsersReferredBy(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.plural(ctx.Lang, "invite.link", invited, i18n.Data{"Link": link}))
+}
+
+// acceptReferral credits the inviter if the user has just started using
+// the bot.
+func (b *Bot) acceptReferral(ctx *Context, referrerId int64) error {
+	greeting := b.t(ctx.Lang, "start.greeting") + "\n\n" + b.helpText(ctx.Lang)
+	if referrerId == ctx.User.Id || time.Since(ctx.User.CreatedAt) > referralWindow {
+		return b.SendMessage(ctx.ChatId, greeting)
+	}
+
+	referrer, err := b.usersDao.GetUserById(referrerId)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return b.SendMessage(ctx.ChatId, greeting)
+	}
+	if err != nil {
+		return err
+	}
+
+	set, err := b.usersDao.SetUserReferrer(ctx.User.Id, referrer.Id)
+	if err != nil {
+		return err
+	}
+
+	if set && !referrer.Banned {
+		lang := b.locale(referrer, nil)
+		err = b.SendMessage(referrer.ChatId, b.t(lang, "invite.joined", i18n.Data{"Name": senderName(ctx)}))
+		if err != nil {
+			logger.Get().Error("Could not notify referrer", zap.Int64("user_id", referrer.Id), zap.Error(err))
+		}
+	}
+
+	return b.SendMessage(ctx.ChatId, greeting)
+}
+
+// HandleTeamCmd gives the user a link to join their team, "/team leave"
+// leaves all teams the user joined.
+func (b *Bot) HandleTeamCmd(ctx *Context) error {
+	if ctx.Args == teamLeaveArg {
+		left, err := b.teamsDao.LeaveTeams(ctx.User.Id)
+		if err != nil {
+			return err
+		}
+		if left == 0 {
+			return errs.NewErrUser("team.none_joined", nil, nil)
+		}
+
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "team.left"))
+	}
+
+	link, err := b.startLink(deeplink.ActionJoinTeam, deeplink.Int64Arg(ctx.User.Id))
+	if err != nil {
+		return err
+	}
+
+	members, err := b.teamsDao.GetTeamMembers(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.plural(ctx.Lang, "team.link", len(members), i18n.Data{"Link": link}))
+}
+
+func (b *Bot) joinTeam(ctx *Context, ownerId int64) error {
+	if ownerId == ctx.User.Id {
+		return errs.NewErrUser("team.own", nil, nil)
+	}
+
+	owner, err := b.usersDao.GetUserById(ownerId)
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && owner.Banned) {
+		return errs.NewErrUser("links.invalid", nil, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = b.teamsDao.AddTeamMember(owner.Id, ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	lang := b.locale(owner, nil)
+	err = b.SendMessage(owner.ChatId, b.t(lang, "team.member_joined", i18n.Data{"Name": senderName(ctx)}))
+	if err != nil {
+		logger.Get().Error("Could not notify team owner", zap.Int64("user_id", owner.Id), zap.Error(err))
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "team.joined"))
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"testing"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/deeplink"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+type fakeLinks struct {
+	dao.Links
+	used map[string]bool
+}
+
+func (f *fakeLinks) UseLink(linkId string, userId int64, expiresAt time.Time) (bool, error) {
+	if f.used[linkId] {
+		return false, nil
+	}
+	f.used[linkId] = true
+
+	return true, nil
+}
+
+func (f *fakeLinks) ReleaseLink(linkId string, userId int64) error {
+	delete(f.used, linkId)
+
+	return nil
+}
+
+// A link whose action failed can be opened again.
+func TestFailedLinkActionReleasesTheLink(t *testing.T) {
+	linksDao := &fakeLinks{used: make(map[string]bool)}
+	b := &Bot{
+		links:     deeplink.NewSigner("secret"),
+		linksDao:  linksDao,
+		sharesDao: &fakeShares{},
+	}
+
+	payload, err := b.links.Sign(deeplink.Payload{
+		Action:    deeplink.ActionAddArticle,
+		Arg:       []byte("missing"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx := &Context{Command: "start", Args: payload, User: &models.User{Id: 1}}
+		err = b.handleStartPayload(ctx)
+
+		userErr, ok := errs.ToUser(err)
+		if !ok || userErr.Key != "share.not_found" {
+			t.Fatalf("attempt %d: got %v, want share.not_found", i+1, err)
+		}
+	}
+	if len(linksDao.used) != 0 {
+		t.Errorf("the link is still used: %v", linksDao.used)
+	}
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/linkcheck"
+	"tg_bot/pkg/models"
+)
+
+const (
+	listLimit = 20
+	// listNoteLength keeps long notes from pushing the list over the
+	// message size limit.
+	listNoteLength = 200
+	// listFooterRoom is kept free for the line counting the articles left
+	// out.
+	listFooterRoom = 64
+)
+
+// HandleListCmd shows the article being read and the oldest unread ones
+// with their notes. Dead links are flagged and get a link to an archived
+// copy.
+func (b *Bot) HandleListCmd(ctx *Context) error {
+	inProgress, err := b.tasksDao.GetUsersTasksByStatus(ctx.User.Id, models.TaskStatusInProgress)
+	if err != nil {
+		return err
+	}
+
+	backlog, err := b.tasksDao.GetUsersOldestTasksByStatus(ctx.User.Id, models.TaskStatusNew, listLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(inProgress) == 0 && len(backlog) == 0 {
+		return errs.NewErrUser("list.empty", nil, nil)
+	}
+
+	var taskIds []int64
+	for _, task := range append(inProgress, backlog...) {
+		taskIds = append(taskIds, task.Id)
+	}
+	notes, err := b.notesDao.GetNotesByTaskIds(taskIds)
+	if err != nil {
+		return err
+	}
+
+	// Articles that don't fit into one message are left out and counted
+	// in the line at the end.
+	var text strings.Builder
+	room := maxMessageLength - listFooterRoom
+	hidden := 0
+	if len(inProgress) > 0 {
+		text.WriteString(b.t(ctx.Lang, "list.in_progress"))
+		text.WriteString("\n")
+		hidden += len(inProgress) - b.writeListTasks(&text, ctx.Lang, inProgress, notes, room, true)
+	}
+
+	if len(backlog) > 0 {
+		shown := 0
+		if hidden == 0 {
+			if text.Len() > 0 {
+				text.WriteString("\n")
+			}
+			text.WriteString(b.t(ctx.Lang, "list.backlog"))
+			text.WriteString("\n")
+			shown = b.writeListTasks(&text, ctx.Lang, backlog, notes, room, len(inProgress) == 0)
+		}
+
+		total, err := b.tasksDao.CountUsersTasksByStatus(ctx.User.Id, models.TaskStatusNew)
+		if err != nil {
+			return err
+		}
+		hidden += total - shown
+	}
+	if hidden > 0 {
+		text.WriteString(b.plural(ctx.Lang, "list.more", hidden))
+		text.WriteString("\n")
+	}
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, text.String())
+	msg.DisableWebPagePreview = true
+	_, err = b.botApi.Send(msg)
+
+	return err
+}
+
+// writeListTasks writes the tasks while the text stays within room and
+// returns how many were written. With cutFirst a first task that doesn't
+// fit is cut instead, so the list is never left without any.
+func (b *Bot) writeListTasks(text *strings.Builder, lang string, tasks []*models.Task, notes map[int64][]*models.Note, room int, cutFirst bool) int {
+	for i, task := range tasks {
+		var entry strings.Builder
+		entry.WriteString(fmt.Sprintf("%d. %s\n", i+1, task.Url))
+		if task.LinkStatus == models.LinkStatusDead {
+			entry.WriteString("   ")
+			entry.WriteString(b.t(lang, "list.dead", i18n.Data{"Url": linkcheck.WaybackURL(task.Url)}))
+			entry.WriteString("\n")
+		}
+		for _, note := range notes[task.Id] {
+			noteText := []rune(note.Text)
+			if len(noteText) > listNoteLength {
+				noteText = append(noteText[:listNoteLength], '…')
+			}
+			entry.WriteString("   📝 ")
+			entry.WriteString(string(noteText))
+			entry.WriteString("\n")
+		}
+
+		left := room - messageLength(text.String())
+		if messageLength(entry.String()) > left {
+			if i == 0 && cutFirst {
+				text.WriteString(cutMessage(entry.String(), left-1))
+				text.WriteString("\n")
+				return 1
+			}
+			return i
+		}
+		text.WriteString(entry.String())
+	}
+
+	return len(tasks)
+}
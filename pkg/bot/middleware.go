@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"time"
+)
+
+const (
+	userRateLimit = rate.Limit(1)
+	userRateBurst = 5
+	// A limiter left alone for limiterIdleAfter is full again, so it can be
+	// dropped and created anew. Idle limiters are looked for every
+	// limiterSweepInterval.
+	limiterIdleAfter     = 10 * time.Minute
+	limiterSweepInterval = time.Minute
+)
+
+// Recover turns a panic in a handler into an error so that a single bad
+// update can't take the update loop down. It has to run inside ErrorReply
+// for the user to get a reply.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Get().Error("Handler panicked",
+						zap.String("command", ctx.Command),
+						zap.Any("panic", r),
+						zap.ByteString("stack", debug.Stack()),
+					)
+					err = fmt.Errorf("handler panicked: %v", r)
+				}
+			}()
+
+			return next(ctx)
+		}
+	}
+}
+
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+
+			fields := []zap.Field{
+				zap.String("command", ctx.Command),
+				zap.Int64("chat_id", ctx.ChatId),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if ctx.User != nil {
+				fields = append(fields, zap.Int64("user_id", ctx.User.Id))
+			}
+
+			if err != nil {
+				if _, ok := errs.ToUser(err); ok {
+					logger.Get().Info("Command rejected", append(fields, zap.Error(err))...)
+					return err
+				}
+
+				fields = append(fields, zap.String("error_ref", errs.RefOf(err)), zap.Error(err))
+				logger.Get().Error("Command failed", fields...)
+				return err
+			}
+
+			logger.Get().Info("Command handled", fields...)
+			return nil
+		}
+	}
+}
+
+func Metrics() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+
+			result := metrics.ResultOk
+			if err != nil {
+				result = metrics.ResultError
+				if _, ok := errs.ToUser(err); ok {
+					result = metrics.ResultRejected
+				}
+			}
+
+			// Unknown commands share a label to keep the cardinality bounded.
+			command := ctx.Command
+			if !ctx.Known {
+				command = "unknown"
+			} else if command == "" {
+				command = "text"
+			}
+
+			metrics.UpdatesProcessed.WithLabelValues(command, result).Inc()
+			metrics.HandlerDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// ErrorReply replies to the user whenever a handler returns an error, so
+// handlers only have to reply on success. Errors the user can act on are
+// shown as is, everything else gets a generic reply with a reference ID.
+func (b *Bot) ErrorReply() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			err := next(ctx)
+			if err != nil {
+				return b.replyError(ctx.ChatId, ctx.Lang, err)
+			}
+
+			return nil
+		}
+	}
+}
+
+type userLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit limits how many commands a single Telegram user can send.
+// Limiters of users that went quiet are evicted, so the map only holds the
+// recently active ones.
+func (b *Bot) RateLimit() Middleware {
+	var mu sync.Mutex
+	limiters := make(map[int64]*userLimiter)
+	lastSweep := time.Now()
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if ctx.From == nil {
+				return next(ctx)
+			}
+
+			now := time.Now()
+			mu.Lock()
+			if now.Sub(lastSweep) >= limiterSweepInterval {
+				for id, l := range limiters {
+					if now.Sub(l.lastSeen) >= limiterIdleAfter {
+						delete(limiters, id)
+					}
+				}
+				lastSweep = now
+			}
+			l, ok := limiters[ctx.From.ID]
+			if !ok {
+				l = &userLimiter{limiter: rate.NewLimiter(userRateLimit, userRateBurst)}
+				limiters[ctx.From.ID] = l
+			}
+			l.lastSeen = now
+			limiter := l.limiter
+			mu.Unlock()
+
+			if !limiter.Allow() {
+				return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "error.rate_limited"))
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// AdminOnly lets only the configured admins through. Everyone else gets
+// the same silence as for an unknown command.
+func (b *Bot) AdminOnly() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if ctx.From == nil || !b.isAdmin(ctx.From.ID) {
+				return nil
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// PrivateOnly refuses commands that reply with the user's secrets outside
+// a private chat with the bot, where the whole group would see them.
+func (b *Bot) PrivateOnly() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if ctx.Message == nil || !ctx.Message.Chat.IsPrivate() {
+				return errs.NewErrUser("error.private_only", nil, nil)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// ResolveUser loads the sender of the message, creating the user on first
+// contact, and picks the reply language.
+func (b *Bot) ResolveUser() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			// Channel posts and some service messages have no sender.
+			if ctx.From == nil {
+				return nil
+			}
+
+			tgUserId := strconv.FormatInt(ctx.From.ID, 10)
+			user, err := b.ensureUserExists(tgUserId, ctx.ChatId)
+			if err != nil {
+				return err
+			}
+
+			// Banned users are ignored without a reply.
+			if user.Banned {
+				return nil
+			}
+
+			err = b.usersDao.UpdateUserLastActive(user.Id)
+			if err != nil {
+				logger.Get().Error("Could not update last activity", zap.Int64("user_id", user.Id), zap.Error(err))
+			}
+
+			username := strings.ToLower(ctx.From.UserName)
+			if user.Username != username {
+				err = b.usersDao.UpdateUserUsername(user.Id, username)
+				if err != nil {
+					logger.Get().Error("Could not update username", zap.Int64("user_id", user.Id), zap.Error(err))
+				}
+				user.Username = username
+			}
+
+			ctx.User = user
+			ctx.Lang = b.locale(user, ctx.From)
+
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+)
+
+const (
+	maxNoteLength = 2000
+	// maxTakeawayPrompts bounds the prompts waiting for an answer, the
+	// oldest ones are forgotten first.
+	maxTakeawayPrompts = 10000
+)
+
+type promptKey struct {
+	chatId    int64
+	messageId int
+}
+
+// takeawayPrompt is the task a takeaway question was about and the
+// Telegram user who was asked.
+type takeawayPrompt struct {
+	taskId int64
+	fromId int64
+}
+
+// takeawayPrompts remembers which task a takeaway question was about, so an
+// answer can be attached to it. Prompts are lost on restart, which only
+// means a late answer is ignored.
+type takeawayPrompts struct {
+	mu    sync.Mutex
+	tasks map[promptKey]takeawayPrompt
+	order []promptKey
+}
+
+func newTakeawayPrompts() *takeawayPrompts {
+	return &takeawayPrompts{tasks: make(map[promptKey]takeawayPrompt)}
+}
+
+func (p *takeawayPrompts) add(key promptKey, prompt takeawayPrompt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.order) >= maxTakeawayPrompts {
+		delete(p.tasks, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.tasks[key] = prompt
+	p.order = append(p.order, key)
+}
+
+// asked reports whether the prompt is waiting for an answer from the user.
+func (p *takeawayPrompts) asked(key promptKey, fromId int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prompt, ok := p.tasks[key]
+
+	return ok && prompt.fromId == fromId
+}
+
+// take returns the task of the prompt and forgets it, a takeaway is saved
+// only once.
+func (p *takeawayPrompts) take(key promptKey) (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prompt, ok := p.tasks[key]
+	delete(p.tasks, key)
+
+	return prompt.taskId, ok
+}
+
+func (b *Bot) HandleNoteCmd(ctx *Context) error {
+	if ctx.Args == "" {
+		return errs.NewErrUser("note.empty", nil, nil)
+	}
+
+	tasks, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		return errs.NewErrUser("tasks.none_in_progress", nil, nil)
+	}
+
+	return b.saveNote(ctx, tasks[0].Id, ctx.Args)
+}
+
+// isTakeawayAnswer reports whether the message replies to a takeaway prompt
+// of the bot and comes from the user it asked. Nothing else in a chat is
+// routed, so other people in groups don't become users by replying.
+func (b *Bot) isTakeawayAnswer(msg *tgbotapi.Message) bool {
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.From == nil || reply.From.ID != b.botApi.Self.ID || msg.From == nil || msg.Text == "" {
+		return false
+	}
+
+	return b.takeaways.asked(promptKey{chatId: msg.Chat.ID, messageId: reply.MessageID}, msg.From.ID)
+}
+
+// HandleText saves answers to takeaway prompts and ignores other messages.
+func (b *Bot) HandleText(ctx *Context) error {
+	reply := ctx.Message.ReplyToMessage
+	taskId, ok := b.takeaways.take(promptKey{chatId: ctx.ChatId, messageId: reply.MessageID})
+	if !ok {
+		return nil
+	}
+
+	task, err := b.tasksDao.GetTaskById(taskId)
+	if err != nil {
+		return err
+	}
+	if task.UserId != ctx.User.Id {
+		return nil
+	}
+
+	return b.saveNote(ctx, task.Id, ctx.Args)
+}
+
+func (b *Bot) saveNote(ctx *Context, taskId int64, text string) error {
+	if runes := []rune(text); len(runes) > maxNoteLength {
+		text = string(runes[:maxNoteLength])
+	}
+
+	err := b.notesDao.InsertNote(&models.Note{TaskId: taskId, Text: text})
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "note.saved"))
+}
+
+// promptTakeaway asks for a one line takeaway of a finished article. The
+// answer is a reply to the prompt, so it can be ignored.
+func (b *Bot) promptTakeaway(ctx *Context, task *models.Task) error {
+	msg := tgbotapi.NewMessage(ctx.ChatId, b.t(ctx.Lang, "note.takeaway_prompt"))
+	msg.ReplyMarkup = tgbotapi.ForceReply{
+		ForceReply:            true,
+		InputFieldPlaceholder: b.t(ctx.Lang, "note.takeaway_placeholder"),
+		Selective:             true,
+	}
+	msg.ReplyToMessageID = ctx.Message.MessageID
+
+	sent, err := b.botApi.Send(msg)
+	if err != nil {
+		return err
+	}
+	b.takeaways.add(promptKey{chatId: ctx.ChatId, messageId: sent.MessageID}, takeawayPrompt{taskId: task.Id, fromId: ctx.From.ID})
+
+	return nil
+}
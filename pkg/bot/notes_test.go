@@ -0,0 +1,49 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"testing"
+)
+
+func TestIsTakeawayAnswer(t *testing.T) {
+	const (
+		botId    = 100
+		userId   = 1
+		chatId   = -500
+		promptId = 10
+	)
+
+	b := &Bot{
+		botApi:    &tgbotapi.BotAPI{Self: tgbotapi.User{ID: botId}},
+		takeaways: newTakeawayPrompts(),
+	}
+	b.takeaways.add(promptKey{chatId: chatId, messageId: promptId}, takeawayPrompt{taskId: 7, fromId: userId})
+
+	message := func(fromId int64, replyTo *tgbotapi.Message) *tgbotapi.Message {
+		return &tgbotapi.Message{
+			From:           &tgbotapi.User{ID: fromId},
+			Chat:           &tgbotapi.Chat{ID: chatId, Type: "group"},
+			Text:           "a takeaway",
+			ReplyToMessage: replyTo,
+		}
+	}
+	prompt := &tgbotapi.Message{MessageID: promptId, From: &tgbotapi.User{ID: botId}}
+
+	tests := []struct {
+		name string
+		msg  *tgbotapi.Message
+		want bool
+	}{
+		{"answer", message(userId, prompt), true},
+		{"someone else", message(2, prompt), false},
+		{"not a reply", message(userId, nil), false},
+		{"reply to a person", message(userId, &tgbotapi.Message{MessageID: promptId, From: &tgbotapi.User{ID: 3}}), false},
+		{"other bot message", message(userId, &tgbotapi.Message{MessageID: promptId + 1, From: &tgbotapi.User{ID: botId}}), false},
+	}
+
+	for _, test := range tests {
+		if got := b.isTakeawayAnswer(test.msg); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strconv"
+	"strings"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/ratings"
+)
+
+const (
+	// nextCandidates is how many random unread articles the next one is
+	// picked from by the user's ratings.
+	nextCandidates = 20
+
+	statsTopTasks = 5
+	statsTopNames = 3
+	// statsMinRatings keeps a single rating from putting a domain or tag
+	// on top.
+	statsMinRatings = 2
+)
+
+// pickNextTask picks a random unread article, preferring domains and tags
+// the user rated well.
+func (b *Bot) pickNextTask(user *models.User) (*models.Task, error) {
+	candidates, err := b.tasksDao.GetUsersRandomTasksByStatus(user.Id, models.TaskStatusNew, nextCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, errs.NewErrNotFound("Task", "user_id", strconv.FormatInt(user.Id, 10))
+	}
+
+	rated, err := b.tasksDao.GetUsersRatedTasks(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ratings.NewPreferences(rated).Pick(candidates), nil
+}
+
+func rateKeyboard(taskId int64) tgbotapi.InlineKeyboardMarkup {
+	var buttons []tgbotapi.InlineKeyboardButton
+	for rating := models.MinRating; rating <= models.MaxRating; rating++ {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			strings.Repeat("⭐", rating),
+			CallbackData("rate", fmt.Sprintf("%d:%d", taskId, rating)),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(buttons)
+}
+
+// HandleRateCallback stores the rating picked under the /done reply and
+// replaces the buttons with a confirmation.
+func (b *Bot) HandleRateCallback(ctx *Context) error {
+	taskId, ratingStr, _ := strings.Cut(ctx.Args, ":")
+	task, err := b.getUsersTask(ctx.User, taskId)
+	if err != nil {
+		return err
+	}
+
+	rating, err := strconv.Atoi(ratingStr)
+	if err != nil || rating < models.MinRating || rating > models.MaxRating {
+		return nil
+	}
+
+	err = b.tasksDao.UpdateTaskRating(task.Id, rating)
+	if err != nil {
+		return err
+	}
+
+	text := b.t(ctx.Lang, "rating.saved", i18n.Data{"Stars": strings.Repeat("⭐", rating)})
+	if ctx.Message != nil && ctx.Message.Text != "" {
+		text = ctx.Message.Text + "\n\n" + text
+	}
+
+	return b.editMessage(ctx, text)
+}
+
+// HandleStatsCmd shows the user's article counts and what they liked most.
+func (b *Bot) HandleStatsCmd(ctx *Context) error {
+	var text strings.Builder
+	text.WriteString(b.t(ctx.Lang, "stats.title"))
+	text.WriteString("\n")
+	for _, status := range models.TaskStatuses {
+		count, err := b.tasksDao.CountUsersTasksByStatus(ctx.User.Id, status)
+		if err != nil {
+			return err
+		}
+		text.WriteString(b.t(ctx.Lang, "stats.status."+strings.ToLower(status), i18n.Data{"Count": count}))
+		text.WriteString("\n")
+	}
+
+	top, err := b.tasksDao.GetUsersTopRatedTasks(ctx.User.Id, statsTopTasks)
+	if err != nil {
+		return err
+	}
+
+	if len(top) == 0 {
+		text.WriteString("\n")
+		text.WriteString(b.t(ctx.Lang, "stats.no_ratings"))
+
+		return b.sendStats(ctx, text.String())
+	}
+
+	text.WriteString("\n")
+	text.WriteString(b.t(ctx.Lang, "stats.top_rated"))
+	text.WriteString("\n")
+	for i, task := range top {
+		text.WriteString(fmt.Sprintf("%d. %s %s\n", i+1, strings.Repeat("⭐", task.Rating), task.Url))
+	}
+
+	rated, err := b.tasksDao.GetUsersRatedTasks(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	prefs := ratings.NewPreferences(rated)
+	writeScores(&text, b.t(ctx.Lang, "stats.top_domains"), "", prefs.TopDomains(statsTopNames, statsMinRatings))
+	writeScores(&text, b.t(ctx.Lang, "stats.top_tags"), "#", prefs.TopTags(statsTopNames, statsMinRatings))
+
+	return b.sendStats(ctx, text.String())
+}
+
+func writeScores(text *strings.Builder, title, prefix string, scores []ratings.Score) {
+	if len(scores) == 0 {
+		return
+	}
+
+	text.WriteString("\n")
+	text.WriteString(title)
+	text.WriteString("\n")
+	for _, s := range scores {
+		text.WriteString(fmt.Sprintf("%s%s: %.1f⭐ (%d)\n", prefix, s.Name, s.Average, s.Count))
+	}
+}
+
+func (b *Bot) sendStats(ctx *Context, text string) error {
+	msg := tgbotapi.NewMessage(ctx.ChatId, text)
+	msg.DisableWebPagePreview = true
+	_, err := b.botApi.Send(msg)
+
+	return err
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"tg_bot/pkg/models"
+)
+
+// Context is passed through the middleware chain to a command or callback
+// handler. User and Lang are filled in by the user resolution middleware.
+type Context struct {
+	Update tgbotapi.Update
+	// Message is the command message, or for callbacks the message the
+	// pressed button belongs to.
+	Message *tgbotapi.Message
+	// Callback is set when the update is an inline button press.
+	Callback *tgbotapi.CallbackQuery
+	// From is the user who sent the command or pressed the button.
+	From    *tgbotapi.User
+	ChatId  int64
+	Command string
+	Args    string
+	User    *models.User
+	Lang    string
+	// Known is set by the router if Command is a registered command.
+	Known bool
+}
+
+type HandlerFunc func(ctx *Context) error
+
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type Command struct {
+	Name string
+	// Description is the message catalog key of the command description
+	// shown in /help and in the Telegram command menu.
+	Description string
+	// Hidden commands are routed but not listed in /help or the menu.
+	Hidden  bool
+	handler HandlerFunc
+}
+
+type Router struct {
+	commands    map[string]*Command
+	callbacks   map[string]HandlerFunc
+	text        HandlerFunc
+	order       []string
+	middlewares []Middleware
+	notFound    HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{
+		commands:  make(map[string]*Command),
+		callbacks: make(map[string]HandlerFunc),
+	}
+}
+
+// Use appends middlewares applied to every command. Middlewares run in the
+// order they were added, so the first one is the outermost.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handle registers a command. The middlewares given here run after the
+// router-wide ones and only for this command.
+func (r *Router) Handle(name, description string, handler HandlerFunc, middlewares ...Middleware) *Command {
+	cmd := &Command{
+		Name:        name,
+		Description: description,
+		handler:     chain(handler, middlewares),
+	}
+	if _, ok := r.commands[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.commands[name] = cmd
+
+	return cmd
+}
+
+// HandleCallback registers a handler for inline button presses. Button data
+// has the form "<name>:<args>", see CallbackData.
+func (r *Router) HandleCallback(name string, handler HandlerFunc, middlewares ...Middleware) {
+	r.callbacks[name] = chain(handler, middlewares)
+}
+
+// HandleText sets the handler for messages that aren't commands. Their
+// Command is empty and Args holds the whole text.
+func (r *Router) HandleText(handler HandlerFunc, middlewares ...Middleware) {
+	r.text = chain(handler, middlewares)
+}
+
+// NotFound sets the handler for unknown commands.
+func (r *Router) NotFound(handler HandlerFunc) {
+	r.notFound = handler
+}
+
+func (r *Router) Dispatch(ctx *Context) error {
+	var handler HandlerFunc
+	if ctx.Callback != nil {
+		handler = r.callbacks[ctx.Command]
+		ctx.Known = handler != nil
+	} else if ctx.Command == "" {
+		handler = r.text
+		ctx.Known = handler != nil
+	} else if cmd, ok := r.commands[ctx.Command]; ok {
+		handler = cmd.handler
+		ctx.Known = true
+	} else {
+		handler = r.notFound
+	}
+	if handler == nil {
+		return nil
+	}
+
+	return chain(handler, r.middlewares)(ctx)
+}
+
+// Commands returns the visible commands in the order they were registered.
+func (r *Router) Commands() []*Command {
+	commands := make([]*Command, 0, len(r.order))
+	for _, name := range r.order {
+		cmd := r.commands[name]
+		if !cmd.Hidden {
+			commands = append(commands, cmd)
+		}
+	}
+
+	return commands
+}
+
+// CallbackData builds the data of an inline button routed to the callback
+// handler with the given name.
+func CallbackData(name, args string) string {
+	return name + ":" + args
+}
+
+func parseCallbackData(data string) (name, args string) {
+	name, args, _ = strings.Cut(data, ":")
+	return name, args
+}
+
+func chain(handler HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"testing"
+	"tg_bot/pkg/config"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+)
+
+// Commands replying with the user's data or secrets must not answer in
+// groups. The handlers aren't reached, the bot has no DAOs.
+func TestPrivateCommandsAreRefusedInGroups(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.API.Enabled = true
+	cfg.SMTP.Domain = "in.example.com"
+	cfg.HTTP.PublicURL = "https://example.com"
+	b := &Bot{cfg: cfg}
+	r := b.newRouter()
+
+	for _, name := range []string{"my_data", "token", "email", "rss"} {
+		for _, chatType := range []string{"group", "supergroup", "channel"} {
+			cmd, ok := r.commands[name]
+			if !ok {
+				t.Fatalf("/%s is not registered", name)
+			}
+
+			ctx := &Context{
+				Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100, Type: chatType}},
+				ChatId:  -100,
+				Command: name,
+				User:    &models.User{Id: 1},
+			}
+			err := cmd.handler(ctx)
+
+			userErr, ok := errs.ToUser(err)
+			if !ok || userErr.Key != "error.private_only" {
+				t.Errorf("/%s in a %s: got %v, want error.private_only", name, chatType, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/listfeed"
+)
+
+const rssOffArg = "off"
+
+// HandleRssCmd shows the secret addresses of the user's reading list as
+// Atom and JSON feeds and "/rss off" turns them off. Only a hash of the
+// token is stored, so every /rss issues new addresses and the old ones stop
+// working.
+func (b *Bot) HandleRssCmd(ctx *Context) error {
+	if ctx.Args == rssOffArg {
+		if ctx.User.FeedTokenHash == "" {
+			return errs.NewErrUser("rss.none", nil, nil)
+		}
+
+		err := b.usersDao.UpdateUserFeedTokenHash(ctx.User.Id, "")
+		if err != nil {
+			return err
+		}
+
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "rss.off"))
+	}
+
+	token, hash, err := listfeed.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = b.usersDao.UpdateUserFeedTokenHash(ctx.User.Id, hash)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, b.t(ctx.Lang, "rss.links", i18n.Data{
+		"Atom": listfeed.URL(b.cfg.HTTP.PublicURL, token, listfeed.FormatAtom),
+		"JSON": listfeed.URL(b.cfg.HTTP.PublicURL, token, listfeed.FormatJSON),
+	}))
+	msg.DisableWebPagePreview = true
+	_, err = b.botApi.Send(msg)
+
+	return err
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strconv"
+	"strings"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+)
+
+const (
+	searchPageSize = 5
+	// maxSearchQuery keeps "search:<offset>:<query>" within the 64 bytes
+	// Telegram allows for button data.
+	maxSearchQuery = 48
+)
+
+func (b *Bot) HandleSearchCmd(ctx *Context) error {
+	if ctx.Args == "" {
+		return errs.NewErrUser("search.empty", nil, nil)
+	}
+	if len(ctx.Args) > maxSearchQuery {
+		return errs.NewErrUser("search.too_long", i18n.Data{"Max": maxSearchQuery}, nil)
+	}
+
+	text, markup, err := b.searchPage(ctx, ctx.Args, 0)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(ctx.ChatId, text)
+	msg.DisableWebPagePreview = true
+	if markup != nil {
+		msg.ReplyMarkup = markup
+	}
+	_, err = b.botApi.Send(msg)
+
+	return err
+}
+
+// HandleSearchPageCallback replaces the results with another page.
+func (b *Bot) HandleSearchPageCallback(ctx *Context) error {
+	offsetArg, query, _ := strings.Cut(ctx.Args, ":")
+	offset, err := strconv.ParseUint(offsetArg, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	text, markup, err := b.searchPage(ctx, query, offset)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(ctx.ChatId, ctx.Message.MessageID, text)
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = markup
+	_, err = b.botApi.Send(edit)
+
+	return err
+}
+
+// searchPage renders one page of results with start reading and delete
+// buttons for each result and buttons to move between pages.
+func (b *Bot) searchPage(ctx *Context, query string, offset uint64) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	// One more result than shown tells whether there is a next page.
+	tasks, err := b.tasksDao.SearchUsersTasks(ctx.User.Id, query, offset, searchPageSize+1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(tasks) == 0 {
+		if offset == 0 {
+			return "", nil, errs.NewErrUser("search.none", i18n.Data{"Query": query}, nil)
+		}
+		return b.t(ctx.Lang, "search.no_more"), nil, nil
+	}
+
+	hasNext := len(tasks) > searchPageSize
+	if hasNext {
+		tasks = tasks[:searchPageSize]
+	}
+
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	text.WriteString(b.t(ctx.Lang, "search.title", i18n.Data{"Query": query}))
+	text.WriteString("\n")
+	for i, task := range tasks {
+		number := int(offset) + i + 1
+		text.WriteString(fmt.Sprintf("%d. %s", number, task.Url))
+		switch task.Status {
+		case models.TaskStatusInProgress:
+			text.WriteString(" " + b.t(ctx.Lang, "search.status_in_progress"))
+		case models.TaskStatusDone:
+			text.WriteString(" " + b.t(ctx.Lang, "search.status_done"))
+		case models.TaskStatusArchived:
+			text.WriteString(" " + b.t(ctx.Lang, "search.status_archived"))
+		}
+		text.WriteString("\n")
+
+		taskId := strconv.FormatInt(task.Id, 10)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "search.button_read", i18n.Data{"Number": number}), CallbackData("read", taskId)),
+			tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "search.button_delete", i18n.Data{"Number": number}), CallbackData("delete", taskId)),
+		))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		prev := uint64(0)
+		if offset > searchPageSize {
+			prev = offset - searchPageSize
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "search.button_prev"), CallbackData("search", fmt.Sprintf("%d:%s", prev, query))))
+	}
+	if hasNext {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(b.t(ctx.Lang, "search.button_next"), CallbackData("search", fmt.Sprintf("%d:%s", offset+searchPageSize, query))))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return text.String(), &markup, nil
+}
+
+// HandleReadCallback makes the chosen article the current one, unless
+// another one is being read.
+func (b *Bot) HandleReadCallback(ctx *Context) error {
+	task, err := b.getUsersTask(ctx.User, ctx.Args)
+	if err != nil {
+		return err
+	}
+
+	if task.Status == models.TaskStatusInProgress {
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "current.task", i18n.Data{"Url": task.Url}))
+	}
+
+	inProgress, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+	if len(inProgress) > 0 {
+		return errs.NewErrNotFinished(inProgress[0])
+	}
+
+	err = b.tasksDao.UpdateTasksStatus([]int64{task.Id}, models.TaskStatusInProgress)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "next.task", i18n.Data{"Url": task.Url}))
+}
+
+func (b *Bot) HandleDeleteCallback(ctx *Context) error {
+	task, err := b.getUsersTask(ctx.User, ctx.Args)
+	if err != nil {
+		return err
+	}
+
+	err = b.tasksDao.DeleteTask(task.Id)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "search.deleted", i18n.Data{"Url": task.Url}))
+}
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/deeplink"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+	"unicode/utf8"
+)
+
+const (
+	// teamUsername shares with all members of the sender's team. Telegram
+	// usernames are at least five characters, so it can't be anyone's.
+	teamUsername    = "team"
+	shareTokenBytes = 16
+	maxFromName     = 128
+)
+
+// HandleShareCmd offers an article to another user with "/share <url>
+// @username", puts it into the lists of the sender's team with "/share <url>
+// @team", or creates a link anyone can open to add it with "/share <url>".
+// An offered article is only added once the recipient accepts it.
+func (b *Bot) HandleShareCmd(ctx *Context) error {
+	username, taskUrl, tags := parseShareArgs(ctx.Args)
+	if taskUrl == "" {
+		return errs.NewErrUser("share.empty_url", nil, nil)
+	}
+	// Shares are stored before the article is added anywhere.
+	err := checkUrlLength(taskUrl)
+	if err != nil {
+		return err
+	}
+
+	fromName := senderName(ctx)
+	if username == "" {
+		return b.shareLink(ctx, fromName, taskUrl, tags)
+	}
+	if username == teamUsername {
+		return b.shareWithTeam(ctx, fromName, taskUrl, tags)
+	}
+
+	recipient, err := b.usersDao.GetUserByUsername(username)
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && recipient.Banned) {
+		return errs.NewErrUser("share.unknown_user", i18n.Data{"Username": username}, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if recipient.Id == ctx.User.Id {
+		return errs.NewErrUser("share.self", nil, nil)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+
+	err = b.sharesDao.InsertShare(&models.Share{
+		Token:       token,
+		UserId:      ctx.User.Id,
+		RecipientId: recipient.Id,
+		FromName:    fromName,
+		Url:         taskUrl,
+		Tags:        tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	lang := b.locale(recipient, nil)
+	msg := tgbotapi.NewMessage(recipient.ChatId, b.t(lang, "share.offered", i18n.Data{"From": fromName, "Url": taskUrl}))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.t(lang, "share.button_accept"), CallbackData("share", token)),
+	))
+	_, err = b.botApi.Send(msg)
+	if err != nil {
+		return errs.NewErrUser("share.not_delivered", i18n.Data{"Username": username}, err)
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "share.sent", i18n.Data{"Username": username}))
+}
+
+// HandleShareCallback adds an offered article once the recipient accepts
+// it. Only the user it was offered to can accept it.
+func (b *Bot) HandleShareCallback(ctx *Context) error {
+	share, err := b.sharesDao.GetShareByToken(ctx.Args)
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && share.RecipientId != ctx.User.Id) {
+		return errs.NewErrUser("share.not_found", nil, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = b.AddTask(ctx.User.Id, share.Url, share.Tags)
+	if err != nil {
+		return err
+	}
+
+	return b.editMessage(ctx, b.t(ctx.Lang, "share.added", i18n.Data{"From": share.FromName, "Url": share.Url}))
+}
+
+func (b *Bot) shareLink(ctx *Context, fromName, taskUrl string, tags []string) error {
+	token := make([]byte, shareTokenBytes)
+	_, err := rand.Read(token)
+	if err != nil {
+		return err
+	}
+
+	// The link is created first, so nothing is stored if links are off.
+	link, err := b.startLink(deeplink.ActionAddArticle, token)
+	if err != nil {
+		return err
+	}
+
+	err = b.sharesDao.InsertShare(&models.Share{
+		Token:    base64.RawURLEncoding.EncodeToString(token),
+		UserId:   ctx.User.Id,
+		FromName: fromName,
+		Url:      taskUrl,
+		Tags:     tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "share.link", i18n.Data{"Link": link}))
+}
+
+func (b *Bot) shareWithTeam(ctx *Context, fromName, taskUrl string, tags []string) error {
+	members, err := b.teamsDao.GetTeamMembers(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	var shared int
+	for _, member := range members {
+		if member.Banned {
+			continue
+		}
+
+		_, err = b.AddTask(member.Id, taskUrl, tags)
+		if err != nil {
+			return err
+		}
+		shared++
+
+		lang := b.locale(member, nil)
+		err = b.SendMessage(member.ChatId, b.t(lang, "share.received", i18n.Data{"From": fromName, "Url": taskUrl}))
+		if err != nil {
+			logger.Get().Error("Could not notify share recipient", zap.Int64("user_id", member.Id), zap.Error(err))
+		}
+	}
+
+	if shared == 0 {
+		return errs.NewErrUser("share.team_empty", nil, nil)
+	}
+
+	return b.SendMessage(ctx.ChatId, b.plural(ctx.Lang, "share.team_sent", shared))
+}
+
+// acceptShare adds the article behind a share link opened by the user.
+func (b *Bot) acceptShare(ctx *Context, token string) error {
+	share, err := b.sharesDao.GetShareByToken(token)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return errs.NewErrUser("share.not_found", nil, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Articles offered to a user are accepted with HandleShareCallback.
+	if share.RecipientId != 0 {
+		return errs.NewErrUser("share.not_found", nil, nil)
+	}
+	if share.UserId == ctx.User.Id {
+		return errs.NewErrUser("share.own", nil, nil)
+	}
+
+	_, err = b.AddTask(ctx.User.Id, share.Url, share.Tags)
+	if err != nil {
+		return err
+	}
+
+	return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "share.added", i18n.Data{"From": share.FromName, "Url": share.Url}))
+}
+
+func newShareToken() (string, error) {
+	token := make([]byte, shareTokenBytes)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// parseShareArgs splits "<url> [#tag...] [@username]" into the recipient's
+// username, lowercased and without "@", the URL and the tags.
+func parseShareArgs(args string) (string, string, []string) {
+	var username string
+	var rest []string
+	for _, word := range strings.Fields(args) {
+		if len(word) > 1 && word[0] == '@' && username == "" {
+			username = strings.ToLower(word[1:])
+			continue
+		}
+		rest = append(rest, word)
+	}
+
+	taskUrl, tags := parseAddArgs(strings.Join(rest, " "))
+
+	return username, taskUrl, tags
+}
+
+// senderName is how the sender of the command is shown to others.
+func senderName(ctx *Context) string {
+	if ctx.From.UserName != "" {
+		return "@" + ctx.From.UserName
+	}
+
+	name := strings.TrimSpace(ctx.From.FirstName + " " + ctx.From.LastName)
+	if utf8.RuneCountInString(name) > maxFromName {
+		name = string([]rune(name)[:maxFromName])
+	}
+
+	return name
+}
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"testing"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+)
+
+type fakeShares struct {
+	dao.Shares
+	shares map[string]*models.Share
+}
+
+func (f *fakeShares) GetShareByToken(token string) (*models.Share, error) {
+	share, ok := f.shares[token]
+	if !ok {
+		return nil, errs.NewErrNotFound("Share", "token", token)
+	}
+
+	return share, nil
+}
+
+// Only the user an article was offered to can accept it. The article isn't
+// added, the bot has no tasks DAO.
+func TestShareCallbackIsRefusedForOthers(t *testing.T) {
+	b := &Bot{sharesDao: &fakeShares{shares: map[string]*models.Share{
+		"offer": {Token: "offer", UserId: 1, RecipientId: 2, Url: "https://example.com"},
+		"link":  {Token: "link", UserId: 1, Url: "https://example.com"},
+	}}}
+
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+	}{
+		{"sender", "offer", 1},
+		{"someone else", "offer", 3},
+		{"link", "link", 2},
+		{"unknown", "missing", 2},
+	}
+
+	for _, test := range tests {
+		ctx := &Context{Command: "share", Args: test.token, User: &models.User{Id: test.userId}}
+		err := b.HandleShareCallback(ctx)
+
+		userErr, ok := errs.ToUser(err)
+		if !ok || userErr.Key != "share.not_found" {
+			t.Errorf("%s: got %v, want share.not_found", test.name, err)
+		}
+	}
+}
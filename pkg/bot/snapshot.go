@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"tg_bot/logger"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+)
+
+// maxCaption is the Telegram limit of a document caption.
+const maxCaption = 1024
+
+const (
+	// snapshotWorkers is how many pages are downloaded at once.
+	snapshotWorkers = 4
+	// snapshotQueueSize is how many added articles may wait for a worker,
+	// articles added while it is full get no snapshot.
+	snapshotQueueSize = 100
+)
+
+// queueSnapshot hands the task to the snapshot workers without waiting, the
+// article is added either way.
+func (b *Bot) queueSnapshot(task *models.Task) {
+	select {
+	case b.snapshotQueue <- task:
+	default:
+		logger.Get().Warn("Snapshot queue is full, skipping snapshot", zap.Int64("task_id", task.Id))
+	}
+}
+
+func (b *Bot) saveSnapshots() {
+	for task := range b.snapshotQueue {
+		b.saveSnapshot(task)
+	}
+}
+
+// saveSnapshot stores the text of a newly added article. It runs in the
+// background, a failure only means there is no snapshot.
+func (b *Bot) saveSnapshot(task *models.Task) {
+	s, err := b.snapshots.Fetch(context.Background(), task)
+	if err != nil {
+		logger.Get().Info("Could not fetch snapshot", zap.Int64("task_id", task.Id), zap.Error(err))
+		return
+	}
+	if s.Text == "" {
+		return
+	}
+
+	err = b.snapshotsDao.InsertSnapshot(s)
+	if err != nil {
+		logger.Get().Error("Could not save snapshot", zap.Int64("task_id", task.Id), zap.Error(err))
+	}
+}
+
+// HandleSnapshotCmd sends the saved text of the current article as a file.
+func (b *Bot) HandleSnapshotCmd(ctx *Context) error {
+	tasks, err := b.tasksDao.GetInProgressTasksByUserId(ctx.User.Id)
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		return errs.NewErrUser("tasks.none_in_progress", nil, nil)
+	}
+
+	s, err := b.snapshotsDao.GetSnapshotByTaskId(tasks[0].Id)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return errs.NewErrUser("snapshot.none", nil, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	content := s.Title + "\n" + tasks[0].Url + "\n\n" + s.Text + "\n"
+	doc := tgbotapi.NewDocument(ctx.ChatId, tgbotapi.FileBytes{Name: "snapshot.txt", Bytes: []byte(content)})
+	doc.Caption = s.Title
+	if caption := []rune(s.Title); len(caption) > maxCaption {
+		doc.Caption = string(caption[:maxCaption])
+	}
+	_, err = b.botApi.Send(doc)
+
+	return err
+}
@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"tg_bot/pkg/api"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/i18n"
+)
+
+const tokenRevokeArg = "revoke"
+
+// HandleTokenCmd issues a new REST API token, replacing the previous one,
+// or revokes it with "/token revoke". The token is only ever shown here.
+func (b *Bot) HandleTokenCmd(ctx *Context) error {
+	if ctx.Args == tokenRevokeArg {
+		deleted, err := b.tokensDao.DeleteUserToken(ctx.User.Id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return errs.NewErrUser("token.none", nil, nil)
+		}
+
+		return b.SendMessage(ctx.ChatId, b.t(ctx.Lang, "token.revoked"))
+	}
+
+	token, hash, err := api.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = b.tokensDao.SetUserToken(ctx.User.Id, hash)
+	if err != nil {
+		return err
+	}
+
+	return b.SendHTMLMessage(ctx.ChatId, b.t(ctx.Lang, "token.created", i18n.Data{"Token": token, "Prefix": api.Prefix}))
+}
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/robfig/cron/v3"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+const minLinksSecret = 32
+
+// webhookSecretRe is what Telegram accepts as the secret_token of a webhook.
+var webhookSecretRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+type Config struct {
+	Bot       BotConfig       `yaml:"bot" toml:"bot"`
+	DB        DBConfig        `yaml:"db" toml:"db"`
+	Reminders RemindersConfig `yaml:"reminders" toml:"reminders"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	HTTP      HTTPConfig      `yaml:"http" toml:"http"`
+	Retention RetentionConfig `yaml:"retention" toml:"retention"`
+	Archive   ArchiveConfig   `yaml:"archive" toml:"archive"`
+	LinkCheck LinkCheckConfig `yaml:"link_check" toml:"link_check"`
+	Snapshots SnapshotsConfig `yaml:"snapshots" toml:"snapshots"`
+	Links     LinksConfig     `yaml:"links" toml:"links"`
+	API       APIConfig       `yaml:"api" toml:"api"`
+	SMTP      SMTPConfig      `yaml:"smtp" toml:"smtp"`
+	Feeds     FeedsConfig     `yaml:"feeds" toml:"feeds"`
+}
+
+type BotConfig struct {
+	ApiKey string `yaml:"api_key" toml:"api_key"`
+	// Mode is either "polling" or "webhook". In webhook mode updates are
+	// received by the HTTP server on WebhookPath.
+	Mode        string `yaml:"mode" toml:"mode"`
+	WebhookURL  string `yaml:"webhook_url" toml:"webhook_url"`
+	WebhookPath string `yaml:"webhook_path" toml:"webhook_path"`
+	// WebhookSecret is sent by Telegram with every update, requests without
+	// it are rejected.
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret"`
+	Debug         bool   `yaml:"debug" toml:"debug"`
+	// Admins are the Telegram user IDs allowed to use the admin commands.
+	Admins []int64 `yaml:"admins" toml:"admins"`
+}
+
+type DBConfig struct {
+	User            string            `yaml:"user" toml:"user"`
+	Password        string            `yaml:"password" toml:"password"`
+	Host            string            `yaml:"host" toml:"host"`
+	Port            int               `yaml:"port" toml:"port"`
+	Name            string            `yaml:"name" toml:"name"`
+	Params          map[string]string `yaml:"params" toml:"params"`
+	MaxOpenConns    int               `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int               `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration     `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	// AutoMigrate applies pending migrations when the bot starts, turn it
+	// off to manage the schema with the migrate command only.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+}
+
+type RemindersConfig struct {
+	// DefaultSchedule and DefaultCount are given to new users.
+	DefaultSchedule string        `yaml:"default_schedule" toml:"default_schedule"`
+	DefaultCount    int           `yaml:"default_count" toml:"default_count"`
+	SyncInterval    time.Duration `yaml:"sync_interval" toml:"sync_interval"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+type HTTPConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+	// PublicURL is where the HTTP server can be reached from outside, e.g.
+	// https://bot.example.com. The reading list feeds of /rss are off
+	// without it.
+	PublicURL string `yaml:"public_url" toml:"public_url"`
+}
+
+type RetentionConfig struct {
+	// InactiveAfter is how long a user may stay silent before they are
+	// deleted with all their articles. Zero keeps users forever.
+	InactiveAfter time.Duration `yaml:"inactive_after" toml:"inactive_after"`
+}
+
+type ArchiveConfig struct {
+	// StaleAfter is the age of unread articles after which users are asked
+	// whether to archive them. Zero turns the proposals off.
+	StaleAfter time.Duration `yaml:"stale_after" toml:"stale_after"`
+}
+
+type LinkCheckConfig struct {
+	// Interval is how often the link of an unread article is checked. Zero
+	// turns the checker off.
+	Interval    time.Duration `yaml:"interval" toml:"interval"`
+	Concurrency int           `yaml:"concurrency" toml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type SnapshotsConfig struct {
+	// Enabled saves the text of every added article for /snapshot.
+	Enabled bool          `yaml:"enabled" toml:"enabled"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// MaxSize is the number of bytes of a page that are downloaded.
+	MaxSize int `yaml:"max_size" toml:"max_size"`
+}
+
+type LinksConfig struct {
+	// Secret signs the invite, team and share links. Links are turned off
+	// without it, changing it invalidates all links handed out before.
+	Secret string `yaml:"secret" toml:"secret"`
+	// TTL is how long a link stays valid.
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+type APIConfig struct {
+	// Enabled serves the REST API for adding articles, e.g. from a browser
+	// extension, on the HTTP server under /api/ and turns on /token.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+}
+
+type SMTPConfig struct {
+	// Addr is where the smtp command accepts mail.
+	Addr string `yaml:"addr" toml:"addr"`
+	// Domain is the mail domain of the users' inbox addresses. It has to
+	// route to Addr, e.g. with an MX record. /email is off without it.
+	Domain string `yaml:"domain" toml:"domain"`
+	// MaxSize is the largest message accepted, in bytes.
+	MaxSize int `yaml:"max_size" toml:"max_size"`
+	// MaxLinks is how many links of a message are added at most.
+	MaxLinks int `yaml:"max_links" toml:"max_links"`
+}
+
+type FeedsConfig struct {
+	// Interval is how often every subscribed feed is checked for new
+	// entries. Zero turns subscriptions off.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout"`
+	// MaxSize is the number of bytes of a feed that are downloaded.
+	MaxSize int `yaml:"max_size" toml:"max_size"`
+	// MaxEntries is how many new entries of a feed are added per check,
+	// the older ones are skipped.
+	MaxEntries int `yaml:"max_entries" toml:"max_entries"`
+	// MaxPerUser is how many feeds a user can subscribe to.
+	MaxPerUser int `yaml:"max_per_user" toml:"max_per_user"`
+}
+
+func Default() *Config {
+	return &Config{
+		Bot: BotConfig{
+			Mode:        ModePolling,
+			WebhookPath: "/telegram/webhook",
+		},
+		DB: DBConfig{
+			Port:            3306,
+			Name:            "read_that_bot",
+			Params:          map[string]string{},
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			AutoMigrate:     true,
+		},
+		Reminders: RemindersConfig{
+			DefaultSchedule: "0 17 * * *",
+			DefaultCount:    1,
+			SyncInterval:    time.Minute,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		HTTP: HTTPConfig{
+			Addr: ":8080",
+		},
+		Archive: ArchiveConfig{
+			StaleAfter: 180 * 24 * time.Hour,
+		},
+		LinkCheck: LinkCheckConfig{
+			Interval:    7 * 24 * time.Hour,
+			Concurrency: 4,
+			Timeout:     10 * time.Second,
+		},
+		Snapshots: SnapshotsConfig{
+			Timeout: 15 * time.Second,
+			MaxSize: 5 << 20,
+		},
+		Links: LinksConfig{
+			TTL: 7 * 24 * time.Hour,
+		},
+		SMTP: SMTPConfig{
+			Addr:     ":2525",
+			MaxSize:  2 << 20,
+			MaxLinks: 10,
+		},
+		Feeds: FeedsConfig{
+			Interval:   time.Hour,
+			Timeout:    15 * time.Second,
+			MaxSize:    2 << 20,
+			MaxEntries: 5,
+			MaxPerUser: 30,
+		},
+	}
+}
+
+// Validate checks the whole config and reports every problem at once.
+func (c *Config) Validate() error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.Bot.ApiKey == "" {
+		add("bot.api_key is required")
+	}
+	switch c.Bot.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if c.Bot.WebhookURL == "" {
+			add("bot.webhook_url is required in webhook mode")
+		} else if u, err := url.Parse(c.Bot.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			add("bot.webhook_url must be an absolute https URL")
+		}
+		if c.Bot.WebhookPath == "" || c.Bot.WebhookPath[0] != '/' {
+			add("bot.webhook_path must start with /")
+		}
+		if !webhookSecretRe.MatchString(c.Bot.WebhookSecret) {
+			add("bot.webhook_secret is required in webhook mode, 1-256 characters A-Z, a-z, 0-9, _ and -")
+		}
+	default:
+		add("bot.mode must be %q or %q, got %q", ModePolling, ModeWebhook, c.Bot.Mode)
+	}
+
+	problems = append(problems, c.DB.validate()...)
+
+	_, err := cron.ParseStandard(c.Reminders.DefaultSchedule)
+	if err != nil {
+		add("reminders.default_schedule is invalid: %v", err)
+	}
+	if c.Reminders.DefaultCount < 1 {
+		add("reminders.default_count must be at least 1")
+	}
+	if c.Reminders.SyncInterval < time.Second {
+		add("reminders.sync_interval must be at least 1s")
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		add("http.addr is invalid: %v", err)
+	}
+	if c.HTTP.PublicURL != "" {
+		if u, err := url.Parse(c.HTTP.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("http.public_url must be an absolute http or https URL")
+		}
+	}
+
+	if c.Retention.InactiveAfter != 0 && c.Retention.InactiveAfter < 24*time.Hour {
+		add("retention.inactive_after must be 0 or at least 24h")
+	}
+	if c.Archive.StaleAfter != 0 && c.Archive.StaleAfter < 24*time.Hour {
+		add("archive.stale_after must be 0 or at least 24h")
+	}
+	if c.LinkCheck.Interval < 0 {
+		add("link_check.interval must not be negative")
+	}
+	if c.LinkCheck.Concurrency < 1 {
+		add("link_check.concurrency must be at least 1")
+	}
+	if c.LinkCheck.Timeout <= 0 {
+		add("link_check.timeout must be positive")
+	}
+	if c.Snapshots.Timeout <= 0 {
+		add("snapshots.timeout must be positive")
+	}
+	if c.Snapshots.MaxSize < 1 {
+		add("snapshots.max_size must be positive")
+	}
+	if c.Links.Secret != "" && len(c.Links.Secret) < minLinksSecret {
+		add("links.secret must be at least %d characters", minLinksSecret)
+	}
+	if c.Links.TTL < time.Hour {
+		add("links.ttl must be at least 1h")
+	}
+	if c.SMTP.Domain != "" {
+		problems = append(problems, c.SMTP.validate()...)
+	}
+	if c.Feeds.Interval != 0 && c.Feeds.Interval < 5*time.Minute {
+		add("feeds.interval must be 0 or at least 5m")
+	}
+	if c.Feeds.Timeout <= 0 {
+		add("feeds.timeout must be positive")
+	}
+	if c.Feeds.MaxSize < 1 {
+		add("feeds.max_size must be positive")
+	}
+	if c.Feeds.MaxEntries < 1 {
+		add("feeds.max_entries must be at least 1")
+	}
+	if c.Feeds.MaxPerUser < 1 {
+		add("feeds.max_per_user must be at least 1")
+	}
+
+	return errors.Join(problems...)
+}
+
+// ValidateDB checks only the database settings, for commands that don't
+// start the bot.
+func (c *Config) ValidateDB() error {
+	return errors.Join(c.DB.validate()...)
+}
+
+// ValidateSMTP checks the settings the smtp command needs.
+func (c *Config) ValidateSMTP() error {
+	problems := c.DB.validate()
+	if c.SMTP.Domain == "" {
+		problems = append(problems, errors.New("smtp.domain is required"))
+	}
+	problems = append(problems, c.SMTP.validate()...)
+
+	return errors.Join(problems...)
+}
+
+func (c *SMTPConfig) validate() []error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		add("smtp.addr is invalid: %v", err)
+	}
+	if c.MaxSize < 1 {
+		add("smtp.max_size must be positive")
+	}
+	if c.MaxLinks < 1 {
+		add("smtp.max_links must be at least 1")
+	}
+
+	return problems
+}
+
+func (c *DBConfig) validate() []error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.User == "" {
+		add("db.user is required")
+	}
+	if c.Password == "" {
+		add("db.password is required")
+	}
+	if c.Host == "" {
+		add("db.host is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		add("db.port must be between 1 and 65535")
+	}
+	if c.Name == "" {
+		add("db.name is required")
+	}
+	if c.MaxOpenConns < 0 {
+		add("db.max_open_conns must not be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		add("db.max_idle_conns must not be negative")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		add("db.max_idle_conns must not be greater than db.max_open_conns")
+	}
+	if c.ConnMaxLifetime < 0 {
+		add("db.conn_max_lifetime must not be negative")
+	}
+
+	return problems
+}
+
+// DSN returns the MySQL data source name. Times are always parsed, the
+// DAOs rely on it.
+func (c *DBConfig) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	cfg.DBName = c.Name
+	cfg.ParseTime = true
+	if len(c.Params) > 0 {
+		cfg.Params = c.Params
+	}
+
+	return cfg.FormatDSN()
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	configFlag = "config"
+	configEnv  = "TG_BOT_CONFIG"
+)
+
+// option is a config value that can be set from the environment and from a
+// command line flag. Both are parsed from strings by set.
+type option struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"api-key", "TG_BOT_API_KEY", "Telegram bot API key", setString(func(c *Config) *string { return &c.Bot.ApiKey })},
+	{"mode", "TG_BOT_MODE", "how updates are received: polling or webhook", setString(func(c *Config) *string { return &c.Bot.Mode })},
+	{"webhook-url", "TG_BOT_WEBHOOK_URL", "public https URL Telegram sends updates to in webhook mode", setString(func(c *Config) *string { return &c.Bot.WebhookURL })},
+	{"webhook-path", "TG_BOT_WEBHOOK_PATH", "HTTP path the webhook is served on", setString(func(c *Config) *string { return &c.Bot.WebhookPath })},
+	{"webhook-secret", "TG_BOT_WEBHOOK_SECRET", "secret Telegram sends with webhook updates", setString(func(c *Config) *string { return &c.Bot.WebhookSecret })},
+	{"debug", "TG_BOT_DEBUG", "log Telegram API requests", setBool(func(c *Config) *bool { return &c.Bot.Debug })},
+	{"admins", "TG_BOT_ADMINS", "comma separated Telegram user IDs of bot admins", setInt64List(func(c *Config) *[]int64 { return &c.Bot.Admins })},
+	{"db-user", "TG_BOT_DB_USER", "database user", setString(func(c *Config) *string { return &c.DB.User })},
+	{"db-password", "TG_BOT_DB_PASSWORD", "database password", setString(func(c *Config) *string { return &c.DB.Password })},
+	{"db-host", "TG_BOT_DB_HOST", "database host", setString(func(c *Config) *string { return &c.DB.Host })},
+	{"db-port", "TG_BOT_DB_PORT", "database port", setInt(func(c *Config) *int { return &c.DB.Port })},
+	{"db-name", "TG_BOT_DB_NAME", "database name", setString(func(c *Config) *string { return &c.DB.Name })},
+	{"db-params", "TG_BOT_DB_PARAMS", "extra DSN parameters, e.g. tls=true&timeout=5s", setParams(func(c *Config) *map[string]string { return &c.DB.Params })},
+	{"db-max-open-conns", "TG_BOT_DB_MAX_OPEN_CONNS", "maximum number of open database connections", setInt(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"db-max-idle-conns", "TG_BOT_DB_MAX_IDLE_CONNS", "maximum number of idle database connections", setInt(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"db-conn-max-lifetime", "TG_BOT_DB_CONN_MAX_LIFETIME", "maximum lifetime of a database connection", setDuration(func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime })},
+	{"db-auto-migrate", "TG_BOT_DB_AUTO_MIGRATE", "apply pending migrations on start", setBool(func(c *Config) *bool { return &c.DB.AutoMigrate })},
+	{"reminders-default-schedule", "TG_BOT_REMINDERS_DEFAULT_SCHEDULE", "cron schedule of reminders for new users", setString(func(c *Config) *string { return &c.Reminders.DefaultSchedule })},
+	{"reminders-default-count", "TG_BOT_REMINDERS_DEFAULT_COUNT", "articles per reminder for new users", setInt(func(c *Config) *int { return &c.Reminders.DefaultCount })},
+	{"reminders-sync-interval", "TG_BOT_REMINDERS_SYNC_INTERVAL", "how often reminder schedules are reloaded", setDuration(func(c *Config) *time.Duration { return &c.Reminders.SyncInterval })},
+	{"log-level", "TG_BOT_LOG_LEVEL", "log level: debug, info, warn or error", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"http-addr", "TG_BOT_HTTP_ADDR", "address of the metrics, health and webhook server", setString(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"http-public-url", "TG_BOT_HTTP_PUBLIC_URL", "URL the HTTP server is reachable at from outside, needed for /rss", setString(func(c *Config) *string { return &c.HTTP.PublicURL })},
+	{"retention-inactive-after", "TG_BOT_RETENTION_INACTIVE_AFTER", "delete users inactive for this long, 0 to keep them", setDuration(func(c *Config) *time.Duration { return &c.Retention.InactiveAfter })},
+	{"archive-stale-after", "TG_BOT_ARCHIVE_STALE_AFTER", "offer to archive unread articles older than this, 0 to never", setDuration(func(c *Config) *time.Duration { return &c.Archive.StaleAfter })},
+	{"link-check-interval", "TG_BOT_LINK_CHECK_INTERVAL", "how often links of unread articles are checked, 0 to never", setDuration(func(c *Config) *time.Duration { return &c.LinkCheck.Interval })},
+	{"link-check-concurrency", "TG_BOT_LINK_CHECK_CONCURRENCY", "number of links checked in parallel", setInt(func(c *Config) *int { return &c.LinkCheck.Concurrency })},
+	{"link-check-timeout", "TG_BOT_LINK_CHECK_TIMEOUT", "timeout of a single link check", setDuration(func(c *Config) *time.Duration { return &c.LinkCheck.Timeout })},
+	{"snapshots-enabled", "TG_BOT_SNAPSHOTS_ENABLED", "save the text of added articles", setBool(func(c *Config) *bool { return &c.Snapshots.Enabled })},
+	{"snapshots-timeout", "TG_BOT_SNAPSHOTS_TIMEOUT", "timeout of downloading an article", setDuration(func(c *Config) *time.Duration { return &c.Snapshots.Timeout })},
+	{"snapshots-max-size", "TG_BOT_SNAPSHOTS_MAX_SIZE", "maximum number of bytes downloaded per article", setInt(func(c *Config) *int { return &c.Snapshots.MaxSize })},
+	{"links-secret", "TG_BOT_LINKS_SECRET", "secret signing invite, team and share links, at least 32 characters", setString(func(c *Config) *string { return &c.Links.Secret })},
+	{"links-ttl", "TG_BOT_LINKS_TTL", "how long invite, team and share links stay valid", setDuration(func(c *Config) *time.Duration { return &c.Links.TTL })},
+	{"api-enabled", "TG_BOT_API_ENABLED", "serve the REST API under /api/ on the HTTP server", setBool(func(c *Config) *bool { return &c.API.Enabled })},
+	{"smtp-addr", "TG_BOT_SMTP_ADDR", "address the smtp command accepts mail on", setString(func(c *Config) *string { return &c.SMTP.Addr })},
+	{"smtp-domain", "TG_BOT_SMTP_DOMAIN", "mail domain of the users' inbox addresses", setString(func(c *Config) *string { return &c.SMTP.Domain })},
+	{"smtp-max-size", "TG_BOT_SMTP_MAX_SIZE", "largest accepted message in bytes", setInt(func(c *Config) *int { return &c.SMTP.MaxSize })},
+	{"smtp-max-links", "TG_BOT_SMTP_MAX_LINKS", "most links added from one message", setInt(func(c *Config) *int { return &c.SMTP.MaxLinks })},
+	{"feeds-interval", "TG_BOT_FEEDS_INTERVAL", "how often subscribed feeds are checked, 0 to turn subscriptions off", setDuration(func(c *Config) *time.Duration { return &c.Feeds.Interval })},
+	{"feeds-timeout", "TG_BOT_FEEDS_TIMEOUT", "timeout of downloading a feed", setDuration(func(c *Config) *time.Duration { return &c.Feeds.Timeout })},
+	{"feeds-max-size", "TG_BOT_FEEDS_MAX_SIZE", "maximum number of bytes downloaded per feed", setInt(func(c *Config) *int { return &c.Feeds.MaxSize })},
+	{"feeds-max-entries", "TG_BOT_FEEDS_MAX_ENTRIES", "most new entries added from one feed per check", setInt(func(c *Config) *int { return &c.Feeds.MaxEntries })},
+	{"feeds-max-per-user", "TG_BOT_FEEDS_MAX_PER_USER", "most feeds a user can subscribe to", setInt(func(c *Config) *int { return &c.Feeds.MaxPerUser })},
+}
+
+// BindFlags registers a flag for every option plus --config.
+func BindFlags(flags *pflag.FlagSet) {
+	flags.String(configFlag, "", "path to a YAML or TOML config file (env "+configEnv+")")
+	for _, opt := range options {
+		flags.String(opt.flag, "", opt.usage+" (env "+opt.env+")")
+	}
+}
+
+// Load builds the config from defaults, the config file, the environment
+// and the flags, each overriding the previous one. The result is not
+// validated.
+func Load(flags *pflag.FlagSet) (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv(configEnv)
+	if flags.Changed(configFlag) {
+		path, _ = flags.GetString(configFlag)
+	}
+	if path != "" {
+		err := loadFile(cfg, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []string
+	for _, opt := range options {
+		value, ok := os.LookupEnv(opt.env)
+		if !ok || value == "" {
+			continue
+		}
+		err := opt.set(cfg, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", opt.env, err))
+		}
+	}
+
+	for _, opt := range options {
+		if !flags.Changed(opt.flag) {
+			continue
+		}
+		value, _ := flags.GetString(opt.flag)
+		err := opt.set(cfg, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("--%s: %v", opt.flag, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config values:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	case ".toml":
+		err = toml.Unmarshal(content, cfg)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func setInt64List(field func(c *Config) *[]int64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []int64
+		for _, item := range strings.Split(value, ",") {
+			v, err := strconv.ParseInt(strings.TrimSpace(item), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a list of integers", value)
+			}
+			list = append(list, v)
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+// setParams parses DSN parameters in query string format. They are merged
+// into the parameters from the config file.
+func setParams(field func(c *Config) *map[string]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		params := *field(c)
+		if params == nil {
+			params = make(map[string]string)
+		}
+		for _, pair := range strings.Split(value, "&") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			params[key] = val
+		}
+		*field(c) = params
+		return nil
+	}
+}
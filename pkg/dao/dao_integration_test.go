@@ -0,0 +1,88 @@
+//go:build integration
+
+package dao
+
+import (
+	"database/sql"
+	"github.com/go-sql-driver/mysql"
+	"os"
+	"testing"
+	"tg_bot/db"
+	"tg_bot/pkg/models"
+)
+
+// The DAO tests run against a real MySQL, see db/migrator_integration_test.go
+// for TG_BOT_TEST_DSN. The database is migrated up for every test and
+// emptied again afterwards.
+const testDSNEnv = "TG_BOT_TEST_DSN"
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	// The DAOs rely on parsed times, see config.DBConfig.DSN.
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", testDSNEnv, err)
+	}
+	cfg.ParseTime = true
+	dsn = cfg.FormatDSN()
+
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	err = conn.Ping()
+	if err != nil {
+		t.Skipf("MySQL is unavailable: %v", err)
+	}
+
+	migrator := db.NewMigrator(dsn)
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Version != 0 {
+		t.Fatalf("the test database is at version %d, it must be empty", status.Version)
+	}
+	err = migrator.Migrate()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		err := migrator.Down(0)
+		if err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	})
+
+	return conn
+}
+
+func insertTestUser(t *testing.T, conn *sql.DB, externalId string) *models.User {
+	t.Helper()
+
+	user, err := NewUsers(conn).InsertUser(&models.User{ExternalId: externalId, ChatId: 1})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	return user
+}
+
+func insertTestTask(t *testing.T, conn *sql.DB, userId int64, url string) *models.Task {
+	t.Helper()
+
+	task, err := NewTasks(conn).InsertTask(&models.Task{UserId: userId, Url: url, Status: models.TaskStatusNew})
+	if err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+
+	return task
+}
@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"strings"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+var feedColumns = []string{"id", "user_id", "url", "title", "etag", "last_modified", "seen_entries", "failures", "checked_at", "created_at"}
+
+// Feeds stores the users' feed subscriptions.
+type Feeds interface {
+	InsertFeed(feed *models.Feed) (*models.Feed, error)
+	GetUsersFeeds(userId int64) ([]*models.Feed, error)
+	// GetFeedsToPoll returns the feeds that weren't checked since
+	// checkedBefore, the longest unchecked first.
+	GetFeedsToPoll(checkedBefore time.Time, limit uint64) ([]*models.Feed, error)
+	// UpdateFeed saves the result of a check.
+	UpdateFeed(feed *models.Feed) error
+	// DeleteUsersFeed returns false if the user had no such feed.
+	DeleteUsersFeed(userId, feedId int64) (bool, error)
+}
+
+type feeds struct {
+	db *sql.DB
+}
+
+func NewFeeds(db *sql.DB) *feeds {
+	return &feeds{db: db}
+}
+
+func (f *feeds) InsertFeed(feed *models.Feed) (*models.Feed, error) {
+	defer metrics.ObserveQuery("feeds", "InsertFeed", time.Now())
+
+	query := sq.Insert("feeds").
+		Columns("user_id", "url", "title", "etag", "last_modified", "seen_entries", "checked_at").
+		Values(feed.UserId, feed.Url, feed.Title, feed.ETag, feed.LastModified, strings.Join(feed.SeenEntries, " "), nullTime(feed.CheckedAt))
+
+	res, err := query.RunWith(f.db).Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	lastId, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	newFeed := *feed
+	newFeed.Id = lastId
+
+	return &newFeed, nil
+}
+
+func (f *feeds) GetUsersFeeds(userId int64) ([]*models.Feed, error) {
+	defer metrics.ObserveQuery("feeds", "GetUsersFeeds", time.Now())
+
+	query := sq.Select(feedColumns...).
+		From("feeds").
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("created_at ASC", "id ASC")
+
+	return f.queryFeeds(query)
+}
+
+func (f *feeds) GetFeedsToPoll(checkedBefore time.Time, limit uint64) ([]*models.Feed, error) {
+	defer metrics.ObserveQuery("feeds", "GetFeedsToPoll", time.Now())
+
+	query := sq.Select(feedColumns...).
+		From("feeds").
+		Where(sq.Or{
+			sq.Eq{"checked_at": nil},
+			sq.Lt{"checked_at": checkedBefore},
+		}).
+		OrderBy("checked_at ASC", "id ASC").
+		Limit(limit)
+
+	return f.queryFeeds(query)
+}
+
+func (f *feeds) UpdateFeed(feed *models.Feed) error {
+	defer metrics.ObserveQuery("feeds", "UpdateFeed", time.Now())
+
+	query := sq.Update("feeds").
+		Set("title", feed.Title).
+		Set("etag", feed.ETag).
+		Set("last_modified", feed.LastModified).
+		Set("seen_entries", strings.Join(feed.SeenEntries, " ")).
+		Set("failures", feed.Failures).
+		Set("checked_at", nullTime(feed.CheckedAt)).
+		Where(sq.Eq{"id": feed.Id})
+
+	_, err := query.RunWith(f.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *feeds) DeleteUsersFeed(userId, feedId int64) (bool, error) {
+	defer metrics.ObserveQuery("feeds", "DeleteUsersFeed", time.Now())
+
+	query := sq.Delete("feeds").
+		Where(sq.Eq{"id": feedId, "user_id": userId})
+
+	res, err := query.RunWith(f.db).Exec()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (f *feeds) queryFeeds(query sq.SelectBuilder) ([]*models.Feed, error) {
+	rows, err := query.RunWith(f.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feedsList []*models.Feed
+	for rows.Next() {
+		var feed models.Feed
+		var seenEntries string
+		var checkedAt sql.NullTime
+		err := rows.Scan(&feed.Id, &feed.UserId, &feed.Url, &feed.Title, &feed.ETag, &feed.LastModified, &seenEntries, &feed.Failures, &checkedAt, &feed.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		feed.SeenEntries = strings.Fields(seenEntries)
+		feed.CheckedAt = checkedAt.Time
+		feedsList = append(feedsList, &feed)
+	}
+
+	return feedsList, rows.Err()
+}
+
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
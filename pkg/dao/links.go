@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"tg_bot/pkg/metrics"
+	"time"
+)
+
+// Links remembers which signed links each user already used.
+type Links interface {
+	// UseLink records that the user opened the link and returns false if
+	// they already did.
+	UseLink(linkId string, userId int64, expiresAt time.Time) (bool, error)
+	// ReleaseLink forgets that the user opened the link, so they can use
+	// it again.
+	ReleaseLink(linkId string, userId int64) error
+	DeleteExpiredLinks(now time.Time) (int, error)
+}
+
+type links struct {
+	db *sql.DB
+}
+
+func NewLinks(db *sql.DB) *links {
+	return &links{db: db}
+}
+
+func (l *links) UseLink(linkId string, userId int64, expiresAt time.Time) (bool, error) {
+	defer metrics.ObserveQuery("links", "UseLink", time.Now())
+
+	query := sq.Insert("used_links").Options("IGNORE").
+		Columns("id", "user_id", "expires_at").
+		Values(linkId, userId, expiresAt)
+
+	res, err := query.RunWith(l.db).Exec()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (l *links) ReleaseLink(linkId string, userId int64) error {
+	defer metrics.ObserveQuery("links", "ReleaseLink", time.Now())
+
+	query := sq.Delete("used_links").
+		Where(sq.Eq{"id": linkId, "user_id": userId})
+
+	_, err := query.RunWith(l.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *links) DeleteExpiredLinks(now time.Time) (int, error) {
+	defer metrics.ObserveQuery("links", "DeleteExpiredLinks", time.Now())
+
+	query := sq.Delete("used_links").
+		Where(sq.Lt{"expires_at": now})
+
+	res, err := query.RunWith(l.db).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+var noteColumns = []string{"task_notes.id", "task_notes.task_id", "task_notes.text", "task_notes.created_at"}
+
+type Notes interface {
+	InsertNote(note *models.Note) error
+	// GetNotesByTaskIds returns the notes grouped by task ID, oldest first.
+	GetNotesByTaskIds(taskIds []int64) (map[int64][]*models.Note, error)
+	GetUsersNotes(userId int64) (map[int64][]*models.Note, error)
+}
+
+type notes struct {
+	db *sql.DB
+}
+
+func NewNotes(db *sql.DB) *notes {
+	return &notes{db: db}
+}
+
+func (n *notes) InsertNote(note *models.Note) error {
+	defer metrics.ObserveQuery("notes", "InsertNote", time.Now())
+
+	query := sq.Insert("task_notes").Columns("task_id", "text").
+		Values(note.TaskId, note.Text)
+
+	_, err := query.RunWith(n.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notes) GetNotesByTaskIds(taskIds []int64) (map[int64][]*models.Note, error) {
+	defer metrics.ObserveQuery("notes", "GetNotesByTaskIds", time.Now())
+
+	if len(taskIds) == 0 {
+		return map[int64][]*models.Note{}, nil
+	}
+
+	query := sq.Select(noteColumns...).
+		From("task_notes").
+		Where(sq.Eq{"task_id": taskIds}).
+		OrderBy("created_at ASC", "id ASC")
+
+	return n.queryNotes(query)
+}
+
+func (n *notes) GetUsersNotes(userId int64) (map[int64][]*models.Note, error) {
+	defer metrics.ObserveQuery("notes", "GetUsersNotes", time.Now())
+
+	query := sq.Select(noteColumns...).
+		From("task_notes").
+		Join("tasks ON tasks.id = task_notes.task_id").
+		Where(sq.Eq{"tasks.user_id": userId}).
+		OrderBy("task_notes.created_at ASC", "task_notes.id ASC")
+
+	return n.queryNotes(query)
+}
+
+func (n *notes) queryNotes(query sq.SelectBuilder) (map[int64][]*models.Note, error) {
+	rows, err := query.RunWith(n.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notesByTask := make(map[int64][]*models.Note)
+	for rows.Next() {
+		var note models.Note
+		err := rows.Scan(&note.Id, &note.TaskId, &note.Text, &note.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notesByTask[note.TaskId] = append(notesByTask[note.TaskId], &note)
+	}
+
+	return notesByTask, rows.Err()
+}
@@ -0,0 +1,136 @@
+package dao
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"go.uber.org/zap"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// booleanOperators have a meaning in MySQL boolean full-text queries and
+// are removed from user input.
+const booleanOperators = `+-<>()~*"@`
+
+// SearchUsersTasks finds the user's tasks whose URL, tags, notes or snapshot
+// match the query. It uses the FULLTEXT indexes, ranking the best matches
+// first, and falls back to LIKE, matching all words, if they can't be used,
+// e.g. on other databases or for words shorter than the full-text minimum.
+// LIKE is also used if the full-text search finds nothing at all, stopwords
+// like "about" or "com" are never in the index.
+func (t *tasks) SearchUsersTasks(userId int64, query string, offset, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "SearchUsersTasks", time.Now())
+
+	words := searchWords(query)
+	if len(words) == 0 {
+		return []*models.Task{}, nil
+	}
+
+	if canUseFullText(words) {
+		tasks, err := t.queryTasks(fullTextSearch(userId, words, offset, limit))
+		if err == nil && len(tasks) == 0 && offset > 0 {
+			// An empty later page is past the last full-text match, unless
+			// there are none and the first page came from LIKE.
+			var first []*models.Task
+			first, err = t.queryTasks(fullTextSearch(userId, words, 0, 1))
+			if err == nil && len(first) > 0 {
+				return tasks, nil
+			}
+		}
+		if err == nil && len(tasks) > 0 {
+			return tasks, nil
+		}
+		if err != nil {
+			logger.Get().Warn("Full-text search failed, falling back to LIKE", zap.Error(err))
+		}
+	}
+
+	return t.queryTasks(likeSearch(userId, words, offset, limit))
+}
+
+func fullTextSearch(userId int64, words []string, offset, limit uint64) sq.SelectBuilder {
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+"*")
+	}
+	against := strings.Join(terms, " ")
+
+	// A task matches if any word is found in the task, its snapshot or its
+	// notes, the relevance of all indexes is summed for ranking.
+	relevance := "MATCH (tasks.url, tasks.tags) AGAINST (? IN BOOLEAN MODE) + " +
+		"COALESCE(MATCH (task_snapshots.title, task_snapshots.search_text) AGAINST (? IN BOOLEAN MODE), 0) + " +
+		"COALESCE((SELECT MAX(MATCH (task_notes.text) AGAINST (? IN BOOLEAN MODE)) FROM task_notes WHERE task_notes.task_id = tasks.id), 0)"
+
+	return searchBase(userId).
+		Where(sq.Expr(relevance+" > 0", against, against, against)).
+		OrderByClause(relevance+" DESC", against, against, against).
+		OrderBy("tasks.id DESC").
+		Offset(offset).
+		Limit(limit)
+}
+
+func likeSearch(userId int64, words []string, offset, limit uint64) sq.SelectBuilder {
+	query := searchBase(userId)
+	for _, word := range words {
+		pattern := "%" + escapeLike(word) + "%"
+		query = query.Where(sq.Or{
+			sq.Like{"tasks.url": pattern},
+			sq.Like{"tasks.tags": pattern},
+			sq.Like{"task_snapshots.title": pattern},
+			sq.Like{"task_snapshots.search_text": pattern},
+			sq.Expr("EXISTS (SELECT 1 FROM task_notes WHERE task_notes.task_id = tasks.id AND task_notes.text LIKE ?)", pattern),
+		})
+	}
+
+	return query.
+		OrderBy("tasks.created_at DESC", "tasks.id DESC").
+		Offset(offset).
+		Limit(limit)
+}
+
+func searchBase(userId int64) sq.SelectBuilder {
+	columns := make([]string, 0, len(taskColumns))
+	for _, column := range taskColumns {
+		columns = append(columns, "tasks."+column)
+	}
+
+	return sq.Select(columns...).
+		From("tasks").
+		LeftJoin("task_snapshots ON task_snapshots.task_id = tasks.id").
+		Where(sq.Eq{"tasks.user_id": userId})
+}
+
+func searchWords(query string) []string {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.TrimLeft(strings.Map(func(r rune) rune {
+			if strings.ContainsRune(booleanOperators, r) {
+				return -1
+			}
+			return r
+		}, word), "#")
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+// canUseFullText reports whether every word is long enough for the InnoDB
+// full-text index, which ignores words shorter than 3 characters.
+func canUseFullText(words []string) bool {
+	for _, word := range words {
+		if len([]rune(word)) < 3 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
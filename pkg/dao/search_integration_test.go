@@ -0,0 +1,38 @@
+//go:build integration
+
+package dao
+
+import (
+	"testing"
+)
+
+func TestSearchStopwords(t *testing.T) {
+	conn := openTestDB(t)
+	tasksDao := NewTasks(conn)
+
+	user := insertTestUser(t, conn, "1")
+	about := insertTestTask(t, conn, user.Id, "https://example.com/about")
+	aboutUs := insertTestTask(t, conn, user.Id, "https://example.org/about-us")
+	insertTestTask(t, conn, user.Id, "https://example.net/team")
+
+	// "about" and "com" are InnoDB stopwords, the full-text search finds
+	// nothing for them without an error.
+	tasks, err := tasksDao.SearchUsersTasks(user.Id, "com", 0, 10)
+	if err != nil {
+		t.Fatalf("com: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Id != about.Id {
+		t.Errorf("com: got %d tasks, want only %s", len(tasks), about.Url)
+	}
+
+	// Every page of a LIKE search stays LIKE, newest first.
+	for page, want := range []int64{aboutUs.Id, about.Id} {
+		tasks, err := tasksDao.SearchUsersTasks(user.Id, "about", uint64(page), 1)
+		if err != nil {
+			t.Fatalf("about, page %d: %v", page, err)
+		}
+		if len(tasks) != 1 || tasks[0].Id != want {
+			t.Errorf("about, page %d: got %v, want task %d", page, tasks, want)
+		}
+	}
+}
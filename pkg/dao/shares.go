@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"strings"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+var shareColumns = []string{"token", "user_id", "recipient_id", "from_name", "url", "tags", "created_at"}
+
+type Shares interface {
+	InsertShare(share *models.Share) error
+	GetShareByToken(token string) (*models.Share, error)
+	// GetUsersShares returns the shares the user created or was offered,
+	// oldest first.
+	GetUsersShares(userId int64) ([]*models.Share, error)
+}
+
+type shares struct {
+	db *sql.DB
+}
+
+func NewShares(db *sql.DB) *shares {
+	return &shares{db: db}
+}
+
+func (s *shares) InsertShare(share *models.Share) error {
+	defer metrics.ObserveQuery("shares", "InsertShare", time.Now())
+
+	recipientId := sql.NullInt64{Int64: share.RecipientId, Valid: share.RecipientId != 0}
+	query := sq.Insert("shares").Columns("token", "user_id", "recipient_id", "from_name", "url", "tags").
+		Values(share.Token, share.UserId, recipientId, share.FromName, share.Url, strings.Join(share.Tags, " "))
+
+	_, err := query.RunWith(s.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *shares) GetShareByToken(token string) (*models.Share, error) {
+	defer metrics.ObserveQuery("shares", "GetShareByToken", time.Now())
+
+	query := sq.Select(shareColumns...).
+		From("shares").
+		Where(sq.Eq{"token": token})
+
+	share, err := scanShare(query.RunWith(s.db).QueryRow())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NewErrNotFound("Share", "token", token)
+		}
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s *shares) GetUsersShares(userId int64) ([]*models.Share, error) {
+	defer metrics.ObserveQuery("shares", "GetUsersShares", time.Now())
+
+	query := sq.Select(shareColumns...).
+		From("shares").
+		Where(sq.Or{sq.Eq{"user_id": userId}, sq.Eq{"recipient_id": userId}}).
+		OrderBy("created_at")
+
+	rows, err := query.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*models.Share
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, share)
+	}
+
+	return result, rows.Err()
+}
+
+func scanShare(row sq.RowScanner) (*models.Share, error) {
+	var share models.Share
+	var recipientId sql.NullInt64
+	var tags string
+	err := row.Scan(&share.Token, &share.UserId, &recipientId, &share.FromName, &share.Url, &tags, &share.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	share.RecipientId = recipientId.Int64
+	share.Tags = strings.Fields(tags)
+
+	return &share, nil
+}
@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"io"
+	"strconv"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxSnapshotTitle = 500
+	// maxSearchText keeps the searchable copy of the text within a MySQL
+	// TEXT column.
+	maxSearchText = 65535
+)
+
+type Snapshots interface {
+	InsertSnapshot(snapshot *models.Snapshot) error
+	GetSnapshotByTaskId(taskId int64) (*models.Snapshot, error)
+}
+
+// snapshots stores the text gzip compressed, article text shrinks to about
+// a third.
+type snapshots struct {
+	db *sql.DB
+}
+
+func NewSnapshots(db *sql.DB) *snapshots {
+	return &snapshots{db: db}
+}
+
+func (s *snapshots) InsertSnapshot(snapshot *models.Snapshot) error {
+	defer metrics.ObserveQuery("snapshots", "InsertSnapshot", time.Now())
+
+	var content bytes.Buffer
+	w := gzip.NewWriter(&content)
+	_, err := io.WriteString(w, snapshot.Text)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	title := []rune(snapshot.Title)
+	if len(title) > maxSnapshotTitle {
+		title = title[:maxSnapshotTitle]
+	}
+
+	query := sq.Insert("task_snapshots").Columns("task_id", "title", "content", "search_text").
+		Values(snapshot.TaskId, string(title), content.Bytes(), truncateBytes(snapshot.Text, maxSearchText))
+
+	_, err = query.RunWith(s.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *snapshots) GetSnapshotByTaskId(taskId int64) (*models.Snapshot, error) {
+	defer metrics.ObserveQuery("snapshots", "GetSnapshotByTaskId", time.Now())
+
+	query := sq.Select("task_id", "title", "content", "created_at").
+		From("task_snapshots").
+		Where(sq.Eq{"task_id": taskId})
+
+	var snapshot models.Snapshot
+	var content []byte
+	err := query.RunWith(s.db).QueryRow().Scan(&snapshot.TaskId, &snapshot.Title, &content, &snapshot.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NewErrNotFound("Snapshot", "task_id", strconv.FormatInt(taskId, 10))
+		}
+		return nil, err
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Text = string(text)
+
+	return &snapshot, nil
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a character.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
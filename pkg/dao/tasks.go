@@ -5,18 +5,44 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"go.uber.org/zap"
 	"strconv"
+	"strings"
 	"tg_bot/logger"
 	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
 	"tg_bot/pkg/models"
+	"time"
 )
 
+var taskColumns = []string{"id", "user_id", "url", "status", "tags", "link_status", "link_status_code", "link_checked_at", "rating", "created_at", "updated_at"}
+
 type Tasks interface {
 	InsertTask(task *models.Task) (*models.Task, error)
 	GetTaskById(taskId int64) (*models.Task, error)
 	GetInProgressTasksByUserId(userId int64) ([]*models.Task, error)
 	UpdateTasksStatus(taskIds []int64, status string) error
+	GetUsersTasks(userId int64) ([]*models.Task, error)
+	GetUsersTaskByUrl(userId int64, url string) (*models.Task, error)
 	GetUsersTasksByStatus(userId int64, status string) ([]*models.Task, error)
 	GetUsersRandomTaskByStatus(userId int64, status string) (*models.Task, error)
+	// GetUsersRandomTasksByStatus skips tasks with dead links, so they are
+	// never handed out.
+	GetUsersRandomTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error)
+	GetUsersTasksByStatusUpdatedSince(userId int64, status string, since time.Time) ([]*models.Task, error)
+	GetUsersOldestTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error)
+	GetUsersRecentTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error)
+	GetUsersStaleTasks(userId int64, createdBefore time.Time, limit uint64) ([]*models.Task, error)
+	MarkTasksArchiveProposed(taskIds []int64) error
+	CountUsersTasksByStatus(userId int64, status string) (int, error)
+	CountUsersTasksCreatedSince(userId int64, since time.Time) (int, error)
+	CountTasksByStatus() (map[string]int, error)
+	UpdateTaskRating(taskId int64, rating int) error
+	GetUsersRatedTasks(userId int64) ([]*models.Task, error)
+	GetUsersTopRatedTasks(userId int64, limit uint64) ([]*models.Task, error)
+	DeleteTask(taskId int64) error
+	DeleteUsersTasks(userId int64) error
+	SearchUsersTasks(userId int64, query string, offset, limit uint64) ([]*models.Task, error)
+	GetTasksToCheck(checkedBefore time.Time, limit uint64) ([]*models.Task, error)
+	UpdateTaskLinkStatus(taskId int64, status string, code int) error
 }
 
 type tasks struct {
@@ -28,8 +54,10 @@ func NewTasks(db *sql.DB) *tasks {
 }
 
 func (t *tasks) InsertTask(task *models.Task) (*models.Task, error) {
-	query := sq.Insert("tasks").Columns("user_id", "url", "status").
-		Values(task.UserId, task.Url, task.Status)
+	defer metrics.ObserveQuery("tasks", "InsertTask", time.Now())
+
+	query := sq.Insert("tasks").Columns("user_id", "url", "status", "tags").
+		Values(task.UserId, task.Url, task.Status, strings.Join(task.Tags, " "))
 
 	res, err := query.RunWith(t.db).Exec()
 	if err != nil {
@@ -50,56 +78,181 @@ func (t *tasks) InsertTask(task *models.Task) (*models.Task, error) {
 }
 
 func (t *tasks) GetTaskById(taskId int64) (*models.Task, error) {
-	query := sq.Select("id", "user_id", "url", "status", "created_at", "updated_at").
+	defer metrics.ObserveQuery("tasks", "GetTaskById", time.Now())
+
+	query := sq.Select(taskColumns...).
 		From("tasks").
 		Where(sq.Eq{"id": taskId})
 
-	rows, err := query.RunWith(t.db).Query()
+	tasksList, err := t.queryTasks(query)
 	if err != nil {
 		return nil, err
 	}
 
-	var task models.Task
-	if rows.Next() {
-		err := rows.Scan(&task.Id, &task.UserId, &task.Url, &task.Status, &task.CreatedAt, &task.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-	} else {
+	if len(tasksList) == 0 {
 		return nil, errs.NewErrNotFound("Task", "id", strconv.FormatInt(taskId, 10))
 	}
 
-	return &task, nil
+	return tasksList[0], nil
 }
 
 func (t *tasks) GetInProgressTasksByUserId(userId int64) ([]*models.Task, error) {
-	query := sq.Select("id", "user_id", "url", "status", "created_at", "updated_at").
+	defer metrics.ObserveQuery("tasks", "GetInProgressTasksByUserId", time.Now())
+
+	return t.GetUsersTasksByStatus(userId, models.TaskStatusInProgress)
+}
+
+func (t *tasks) UpdateTasksStatus(taskIds []int64, status string) error {
+	defer metrics.ObserveQuery("tasks", "UpdateTasksStatus", time.Now())
+
+	query := sq.Update("tasks").
+		Set("status", status).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": taskIds})
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *tasks) GetUsersTasks(userId int64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersTasks", time.Now())
+
+	query := sq.Select(taskColumns...).
 		From("tasks").
 		Where(sq.Eq{"user_id": userId}).
-		Where(sq.Eq{"status": models.TaskStatusInProgress})
+		OrderBy("created_at")
 
-	rows, err := query.RunWith(t.db).Query()
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersTaskByUrl(userId int64, url string) (*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersTaskByUrl", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId, "url": url}).
+		Limit(1)
+
+	tasksList, err := t.queryTasks(query)
 	if err != nil {
 		return nil, err
 	}
 
-	var tasksList = make([]*models.Task, 0)
-	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(&task.Id, &task.UserId, &task.Url, &task.Status, &task.CreatedAt, &task.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		tasksList = append(tasksList, &task)
+	if len(tasksList) == 0 {
+		return nil, errs.NewErrNotFound("Task", "url", url)
 	}
 
-	return tasksList, nil
+	return tasksList[0], nil
 }
 
-func (t *tasks) UpdateTasksStatus(taskIds []int64, status string) error {
+func (t *tasks) GetUsersTasksByStatus(userId int64, status string) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersTasksByStatus", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": status})
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersRandomTaskByStatus(userId int64, status string) (*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersRandomTaskByStatus", time.Now())
+
+	tasksList, err := t.GetUsersRandomTasksByStatus(userId, status, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasksList) == 0 {
+		return nil, errs.NewErrNotFound("Task", "user_id", strconv.FormatInt(userId, 10))
+	}
+
+	return tasksList[0], nil
+}
+
+func (t *tasks) GetUsersRandomTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersRandomTasksByStatus", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": status}).
+		Where(sq.NotEq{"link_status": models.LinkStatusDead}).
+		OrderBy("RAND()").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersTasksByStatusUpdatedSince(userId int64, status string, since time.Time) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersTasksByStatusUpdatedSince", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": status}).
+		Where(sq.GtOrEq{"updated_at": since}).
+		OrderBy("updated_at DESC")
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersOldestTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersOldestTasksByStatus", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": status}).
+		OrderBy("created_at ASC", "id ASC").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersRecentTasksByStatus(userId int64, status string, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersRecentTasksByStatus", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": status}).
+		OrderBy("updated_at DESC", "id DESC").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
+
+// GetUsersStaleTasks returns NEW tasks created before createdBefore that
+// weren't proposed for archiving since then, oldest first.
+func (t *tasks) GetUsersStaleTasks(userId int64, createdBefore time.Time, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersStaleTasks", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": models.TaskStatusNew}).
+		Where(sq.Lt{"created_at": createdBefore}).
+		Where(sq.Or{
+			sq.Eq{"archive_proposed_at": nil},
+			sq.Lt{"archive_proposed_at": createdBefore},
+		}).
+		OrderBy("created_at ASC", "id ASC").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) MarkTasksArchiveProposed(taskIds []int64) error {
+	defer metrics.ObserveQuery("tasks", "MarkTasksArchiveProposed", time.Now())
+
 	query := sq.Update("tasks").
-		Set("status", status).
-		Set("updated_at", sq.Expr("NOW()")).
+		Set("archive_proposed_at", sq.Expr("NOW()")).
 		Where(sq.Eq{"id": taskIds})
 
 	_, err := query.RunWith(t.db).Exec()
@@ -110,52 +263,191 @@ func (t *tasks) UpdateTasksStatus(taskIds []int64, status string) error {
 	return nil
 }
 
-func (t *tasks) GetUsersTasksByStatus(userId int64, status string) ([]*models.Task, error) {
-	query := sq.Select("id", "user_id", "url", "status", "created_at", "updated_at").
+func (t *tasks) CountUsersTasksByStatus(userId int64, status string) (int, error) {
+	defer metrics.ObserveQuery("tasks", "CountUsersTasksByStatus", time.Now())
+
+	query := sq.Select("COUNT(*)").
 		From("tasks").
 		Where(sq.Eq{"user_id": userId}).
 		Where(sq.Eq{"status": status})
 
+	return t.queryCount(query)
+}
+
+func (t *tasks) CountUsersTasksCreatedSince(userId int64, since time.Time) (int, error) {
+	defer metrics.ObserveQuery("tasks", "CountUsersTasksCreatedSince", time.Now())
+
+	query := sq.Select("COUNT(*)").
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.GtOrEq{"created_at": since})
+
+	return t.queryCount(query)
+}
+
+func (t *tasks) CountTasksByStatus() (map[string]int, error) {
+	defer metrics.ObserveQuery("tasks", "CountTasksByStatus", time.Now())
+
+	query := sq.Select("status", "COUNT(*)").
+		From("tasks").
+		GroupBy("status")
+
 	rows, err := query.RunWith(t.db).Query()
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var tasksList = make([]*models.Task, 0)
+	counts := make(map[string]int)
 	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(&task.Id, &task.UserId, &task.Url, &task.Status, &task.CreatedAt, &task.UpdatedAt)
+		var status string
+		var count int
+		err := rows.Scan(&status, &count)
 		if err != nil {
 			return nil, err
 		}
-		tasksList = append(tasksList, &task)
+		counts[status] = count
 	}
 
-	return tasksList, nil
+	return counts, rows.Err()
 }
 
-func (t *tasks) GetUsersRandomTaskByStatus(userId int64, status string) (*models.Task, error) {
-	query := sq.Select("id", "user_id", "url", "status", "created_at", "updated_at").
+func (t *tasks) UpdateTaskRating(taskId int64, rating int) error {
+	defer metrics.ObserveQuery("tasks", "UpdateTaskRating", time.Now())
+
+	query := sq.Update("tasks").
+		Set("rating", rating).
+		Where(sq.Eq{"id": taskId})
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *tasks) GetUsersRatedTasks(userId int64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersRatedTasks", time.Now())
+
+	query := sq.Select(taskColumns...).
 		From("tasks").
 		Where(sq.Eq{"user_id": userId}).
-		Where(sq.Eq{"status": status}).
-		OrderBy("RAND()").
-		Limit(1)
+		Where(sq.Gt{"rating": 0})
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) GetUsersTopRatedTasks(userId int64, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetUsersTopRatedTasks", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Gt{"rating": 0}).
+		OrderBy("rating DESC", "updated_at DESC").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
+
+func (t *tasks) DeleteTask(taskId int64) error {
+	defer metrics.ObserveQuery("tasks", "DeleteTask", time.Now())
+
+	query := sq.Delete("tasks").
+		Where(sq.Eq{"id": taskId})
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *tasks) DeleteUsersTasks(userId int64) error {
+	defer metrics.ObserveQuery("tasks", "DeleteUsersTasks", time.Now())
+
+	query := sq.Delete("tasks").
+		Where(sq.Eq{"user_id": userId})
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetTasksToCheck returns NEW tasks whose links were never checked or were
+// last checked before checkedBefore, least recently checked first.
+func (t *tasks) GetTasksToCheck(checkedBefore time.Time, limit uint64) ([]*models.Task, error) {
+	defer metrics.ObserveQuery("tasks", "GetTasksToCheck", time.Now())
+
+	query := sq.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"status": models.TaskStatusNew}).
+		Where(sq.Or{
+			sq.Eq{"link_checked_at": nil},
+			sq.Lt{"link_checked_at": checkedBefore},
+		}).
+		OrderBy("link_checked_at ASC", "id ASC").
+		Limit(limit)
+
+	return t.queryTasks(query)
+}
 
+// UpdateTaskLinkStatus records a link check. An empty status keeps the
+// previous one, e.g. when the server was temporarily down.
+func (t *tasks) UpdateTaskLinkStatus(taskId int64, status string, code int) error {
+	defer metrics.ObserveQuery("tasks", "UpdateTaskLinkStatus", time.Now())
+
+	query := sq.Update("tasks").
+		Set("link_status_code", code).
+		Set("link_checked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": taskId})
+	if status != "" {
+		query = query.Set("link_status", status)
+	}
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *tasks) queryTasks(query sq.SelectBuilder) ([]*models.Task, error) {
 	rows, err := query.RunWith(t.db).Query()
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var task models.Task
-	if rows.Next() {
-		err := rows.Scan(&task.Id, &task.UserId, &task.Url, &task.Status, &task.CreatedAt, &task.UpdatedAt)
+	var tasksList = make([]*models.Task, 0)
+	for rows.Next() {
+		var task models.Task
+		var tags string
+		var linkCheckedAt sql.NullTime
+		err := rows.Scan(&task.Id, &task.UserId, &task.Url, &task.Status, &tags, &task.LinkStatus, &task.LinkStatusCode, &linkCheckedAt, &task.Rating, &task.CreatedAt, &task.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		return nil, errs.NewErrNotFound("Task", "user_id", strconv.FormatInt(userId, 10))
+		task.Tags = strings.Fields(tags)
+		task.LinkCheckedAt = linkCheckedAt.Time
+		tasksList = append(tasksList, &task)
+	}
+
+	return tasksList, rows.Err()
+}
+
+func (t *tasks) queryCount(query sq.SelectBuilder) (int, error) {
+	var count int
+	err := query.RunWith(t.db).QueryRow().Scan(&count)
+	if err != nil {
+		return 0, err
 	}
 
-	return &task, nil
+	return count, nil
 }
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// Teams keeps who joined whose team. Every user owns one team, the articles
+// they share with @team go to all of its members.
+type Teams interface {
+	AddTeamMember(ownerId, memberId int64) error
+	GetTeamMembers(ownerId int64) ([]*models.User, error)
+	// GetJoinedTeams returns the owners of the teams the user joined.
+	GetJoinedTeams(memberId int64) ([]*models.User, error)
+	// LeaveTeams removes the user from all teams they joined and returns
+	// how many they left.
+	LeaveTeams(memberId int64) (int, error)
+}
+
+type teams struct {
+	db *sql.DB
+}
+
+func NewTeams(db *sql.DB) *teams {
+	return &teams{db: db}
+}
+
+func (t *teams) AddTeamMember(ownerId, memberId int64) error {
+	defer metrics.ObserveQuery("teams", "AddTeamMember", time.Now())
+
+	// Joining twice is not an error.
+	query := sq.Insert("team_members").Options("IGNORE").
+		Columns("owner_id", "member_id").
+		Values(ownerId, memberId)
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *teams) GetTeamMembers(ownerId int64) ([]*models.User, error) {
+	defer metrics.ObserveQuery("teams", "GetTeamMembers", time.Now())
+
+	return t.getTeamUsers("member_id", "owner_id", ownerId)
+}
+
+func (t *teams) GetJoinedTeams(memberId int64) ([]*models.User, error) {
+	defer metrics.ObserveQuery("teams", "GetJoinedTeams", time.Now())
+
+	return t.getTeamUsers("owner_id", "member_id", memberId)
+}
+
+// getTeamUsers returns the users in the userColumn of the team_members rows
+// where idColumn is id.
+func (t *teams) getTeamUsers(userColumn, idColumn string, id int64) ([]*models.User, error) {
+	columns := make([]string, 0, len(userColumns))
+	for _, column := range userColumns {
+		columns = append(columns, "users."+column)
+	}
+
+	query := sq.Select(columns...).
+		From("team_members").
+		Join("users ON users.id = team_members." + userColumn).
+		Where(sq.Eq{"team_members." + idColumn: id}).
+		OrderBy("team_members.created_at")
+
+	rows, err := query.RunWith(t.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (t *teams) LeaveTeams(memberId int64) (int, error) {
+	defer metrics.ObserveQuery("teams", "LeaveTeams", time.Now())
+
+	query := sq.Delete("team_members").
+		Where(sq.Eq{"member_id": memberId})
+
+	res, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
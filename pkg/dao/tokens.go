@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"strconv"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// Tokens stores the hashes of the users' API tokens, a user has at most one
+// token.
+type Tokens interface {
+	// SetUserToken replaces the user's token.
+	SetUserToken(userId int64, tokenHash string) error
+	// GetUserIdByTokenHash also records that the token was used.
+	GetUserIdByTokenHash(tokenHash string) (int64, error)
+	GetUserToken(userId int64) (*models.Token, error)
+	// DeleteUserToken returns false if the user had no token.
+	DeleteUserToken(userId int64) (bool, error)
+}
+
+type tokens struct {
+	db *sql.DB
+}
+
+func NewTokens(db *sql.DB) *tokens {
+	return &tokens{db: db}
+}
+
+func (t *tokens) SetUserToken(userId int64, tokenHash string) error {
+	defer metrics.ObserveQuery("tokens", "SetUserToken", time.Now())
+
+	query := sq.Insert("api_tokens").Columns("user_id", "token_hash").
+		Values(userId, tokenHash).
+		Suffix("ON DUPLICATE KEY UPDATE token_hash = VALUES(token_hash), created_at = NOW(), last_used_at = NULL")
+
+	_, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *tokens) GetUserIdByTokenHash(tokenHash string) (int64, error) {
+	defer metrics.ObserveQuery("tokens", "GetUserIdByTokenHash", time.Now())
+
+	query := sq.Select("user_id").
+		From("api_tokens").
+		Where(sq.Eq{"token_hash": tokenHash})
+
+	var userId int64
+	err := query.RunWith(t.db).QueryRow().Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errs.NewErrNotFound("Token", "token_hash", tokenHash)
+		}
+		return 0, err
+	}
+
+	update := sq.Update("api_tokens").
+		Set("last_used_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"user_id": userId})
+
+	_, err = update.RunWith(t.db).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (t *tokens) GetUserToken(userId int64) (*models.Token, error) {
+	defer metrics.ObserveQuery("tokens", "GetUserToken", time.Now())
+
+	query := sq.Select("user_id", "created_at", "last_used_at").
+		From("api_tokens").
+		Where(sq.Eq{"user_id": userId})
+
+	var token models.Token
+	var lastUsedAt sql.NullTime
+	err := query.RunWith(t.db).QueryRow().Scan(&token.UserId, &token.CreatedAt, &lastUsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NewErrNotFound("Token", "user_id", strconv.FormatInt(userId, 10))
+		}
+		return nil, err
+	}
+	token.LastUsedAt = lastUsedAt.Time
+
+	return &token, nil
+}
+
+func (t *tokens) DeleteUserToken(userId int64) (bool, error) {
+	defer metrics.ObserveQuery("tokens", "DeleteUserToken", time.Now())
+
+	query := sq.Delete("api_tokens").
+		Where(sq.Eq{"user_id": userId})
+
+	res, err := query.RunWith(t.db).Exec()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
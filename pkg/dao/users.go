@@ -7,14 +7,39 @@ import (
 	"strconv"
 	"tg_bot/logger"
 	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
 	"tg_bot/pkg/models"
+	"time"
 )
 
+var userColumns = []string{"id", "external_id", "username", "chat_id", "reminder_schedule", "reminder_count", "digest_enabled", "language", "banned", "last_active_at", "referred_by", "inbox_token", "feed_token_hash", "created_at", "updated_at"}
+
 type Users interface {
 	InsertUser(user *models.User) (*models.User, error)
 	GetUserById(userId int64) (*models.User, error)
 	GetUserByExternalId(externalId string) (*models.User, error)
+	// GetUserByUsername returns the most recently active user with the
+	// username, since an old one may have been taken over by someone else.
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByInboxToken(token string) (*models.User, error)
+	GetUserByFeedTokenHash(hash string) (*models.User, error)
 	GetAllUsers() ([]*models.User, error)
+	GetUsersInactiveSince(since time.Time) ([]*models.User, error)
+	CountUsers() (int, error)
+	UpdateUserReminderSchedule(userId int64, schedule string, count int) error
+	UpdateUserDigestEnabled(userId int64, enabled bool) error
+	UpdateUserLanguage(userId int64, language string) error
+	UpdateUserBanned(userId int64, banned bool) error
+	UpdateUserLastActive(userId int64) error
+	UpdateUserUsername(userId int64, username string) error
+	// SetUserReferrer records who invited the user, unless it is already
+	// known. It returns false if it was.
+	SetUserReferrer(userId, referrerId int64) (bool, error)
+	CountUsersReferredBy(referrerId int64) (int, error)
+	UpdateUserInboxToken(userId int64, token string) error
+	// UpdateUserFeedTokenHash turns the feeds off if hash is empty.
+	UpdateUserFeedTokenHash(userId int64, hash string) error
+	DeleteUser(userId int64) error
 }
 
 type users struct {
@@ -26,8 +51,10 @@ func NewUsers(db *sql.DB) *users {
 }
 
 func (u *users) InsertUser(user *models.User) (*models.User, error) {
-	query := sq.Insert("users").Columns("external_id", "chat_id").
-		Values(user.ExternalId, user.ChatId)
+	defer metrics.ObserveQuery("users", "InsertUser", time.Now())
+
+	query := sq.Insert("users").Columns("external_id", "chat_id", "reminder_schedule", "reminder_count").
+		Values(user.ExternalId, user.ChatId, user.ReminderSchedule, user.ReminderCount)
 
 	res, err := query.RunWith(u.db).Exec()
 	if err != nil {
@@ -49,7 +76,9 @@ func (u *users) InsertUser(user *models.User) (*models.User, error) {
 }
 
 func (u *users) GetUserById(userId int64) (*models.User, error) {
-	query := sq.Select("id", "external_id", "created_at", "updated_at").
+	defer metrics.ObserveQuery("users", "GetUserById", time.Now())
+
+	query := sq.Select(userColumns...).
 		From("users").
 		Where(sq.Eq{"id": userId})
 
@@ -57,24 +86,21 @@ func (u *users) GetUserById(userId int64) (*models.User, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var user models.User
-	if rows.Next() {
-		err = rows.Scan(&user.Id, &user.ExternalId, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-	} else {
+	if !rows.Next() {
 		idStr := strconv.FormatInt(userId, 10)
 
 		return nil, errs.NewErrNotFound("User", "id", idStr)
 	}
 
-	return &user, nil
+	return scanUser(rows)
 }
 
 func (u *users) GetUserByExternalId(externalId string) (*models.User, error) {
-	query := sq.Select("id", "external_id", "created_at", "updated_at").
+	defer metrics.ObserveQuery("users", "GetUserByExternalId", time.Now())
+
+	query := sq.Select(userColumns...).
 		From("users").
 		Where(sq.Eq{"external_id": externalId})
 
@@ -82,38 +108,348 @@ func (u *users) GetUserByExternalId(externalId string) (*models.User, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var user models.User
-	if rows.Next() {
-		err = rows.Scan(&user.Id, &user.ExternalId, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-	} else {
+	if !rows.Next() {
 		return nil, errs.NewErrNotFound("User", "external_id", externalId)
 	}
 
-	return &user, nil
+	return scanUser(rows)
+}
+
+func (u *users) GetUserByUsername(username string) (*models.User, error) {
+	defer metrics.ObserveQuery("users", "GetUserByUsername", time.Now())
+
+	query := sq.Select(userColumns...).
+		From("users").
+		Where(sq.Eq{"username": username}).
+		OrderBy("last_active_at DESC").
+		Limit(1)
+
+	rows, err := query.RunWith(u.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errs.NewErrNotFound("User", "username", username)
+	}
+
+	return scanUser(rows)
+}
+
+func (u *users) GetUserByInboxToken(token string) (*models.User, error) {
+	defer metrics.ObserveQuery("users", "GetUserByInboxToken", time.Now())
+
+	query := sq.Select(userColumns...).
+		From("users").
+		Where(sq.Eq{"inbox_token": token})
+
+	rows, err := query.RunWith(u.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errs.NewErrNotFound("User", "inbox_token", token)
+	}
+
+	return scanUser(rows)
+}
+
+func (u *users) GetUserByFeedTokenHash(hash string) (*models.User, error) {
+	defer metrics.ObserveQuery("users", "GetUserByFeedTokenHash", time.Now())
+
+	query := sq.Select(userColumns...).
+		From("users").
+		Where(sq.Eq{"feed_token_hash": hash})
+
+	rows, err := query.RunWith(u.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errs.NewErrNotFound("User", "feed_token_hash", hash)
+	}
+
+	return scanUser(rows)
 }
 
 func (u *users) GetAllUsers() ([]*models.User, error) {
-	query := sq.Select("id", "external_id", "chat_id", "created_at", "updated_at").
+	defer metrics.ObserveQuery("users", "GetAllUsers", time.Now())
+
+	query := sq.Select(userColumns...).
 		From("users")
 
 	rows, err := query.RunWith(u.db).Query()
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (u *users) GetUsersInactiveSince(since time.Time) ([]*models.User, error) {
+	defer metrics.ObserveQuery("users", "GetUsersInactiveSince", time.Now())
+
+	query := sq.Select(userColumns...).
+		From("users").
+		Where(sq.Lt{"last_active_at": since})
+
+	rows, err := query.RunWith(u.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	var users []*models.User
 	for rows.Next() {
-		var user models.User
-		err = rows.Scan(&user.Id, &user.ExternalId, &user.ChatId, &user.CreatedAt, &user.UpdatedAt)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	return users, nil
 }
+
+func (u *users) CountUsers() (int, error) {
+	defer metrics.ObserveQuery("users", "CountUsers", time.Now())
+
+	query := sq.Select("COUNT(*)").
+		From("users")
+
+	var count int
+	err := query.RunWith(u.db).QueryRow().Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (u *users) UpdateUserReminderSchedule(userId int64, schedule string, count int) error {
+	defer metrics.ObserveQuery("users", "UpdateUserReminderSchedule", time.Now())
+
+	query := sq.Update("users").
+		Set("reminder_schedule", schedule).
+		Set("reminder_count", count).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserDigestEnabled(userId int64, enabled bool) error {
+	defer metrics.ObserveQuery("users", "UpdateUserDigestEnabled", time.Now())
+
+	query := sq.Update("users").
+		Set("digest_enabled", enabled).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserLanguage(userId int64, language string) error {
+	defer metrics.ObserveQuery("users", "UpdateUserLanguage", time.Now())
+
+	query := sq.Update("users").
+		Set("language", language).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserBanned(userId int64, banned bool) error {
+	defer metrics.ObserveQuery("users", "UpdateUserBanned", time.Now())
+
+	query := sq.Update("users").
+		Set("banned", banned).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserLastActive(userId int64) error {
+	defer metrics.ObserveQuery("users", "UpdateUserLastActive", time.Now())
+
+	query := sq.Update("users").
+		Set("last_active_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserUsername(userId int64, username string) error {
+	defer metrics.ObserveQuery("users", "UpdateUserUsername", time.Now())
+
+	query := sq.Update("users").
+		Set("username", username).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) SetUserReferrer(userId, referrerId int64) (bool, error) {
+	defer metrics.ObserveQuery("users", "SetUserReferrer", time.Now())
+
+	query := sq.Update("users").
+		Set("referred_by", referrerId).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId, "referred_by": nil})
+
+	res, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (u *users) CountUsersReferredBy(referrerId int64) (int, error) {
+	defer metrics.ObserveQuery("users", "CountUsersReferredBy", time.Now())
+
+	query := sq.Select("COUNT(*)").
+		From("users").
+		Where(sq.Eq{"referred_by": referrerId})
+
+	var count int
+	err := query.RunWith(u.db).QueryRow().Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (u *users) UpdateUserInboxToken(userId int64, token string) error {
+	defer metrics.ObserveQuery("users", "UpdateUserInboxToken", time.Now())
+
+	query := sq.Update("users").
+		Set("inbox_token", token).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) UpdateUserFeedTokenHash(userId int64, hash string) error {
+	defer metrics.ObserveQuery("users", "UpdateUserFeedTokenHash", time.Now())
+
+	query := sq.Update("users").
+		Set("feed_token_hash", sql.NullString{String: hash, Valid: hash != ""}).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *users) DeleteUser(userId int64) error {
+	defer metrics.ObserveQuery("users", "DeleteUser", time.Now())
+
+	query := sq.Delete("users").
+		Where(sq.Eq{"id": userId})
+
+	_, err := query.RunWith(u.db).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanUser(rows *sql.Rows) (*models.User, error) {
+	var user models.User
+	var referredBy sql.NullInt64
+	var inboxToken sql.NullString
+	var feedTokenHash sql.NullString
+	err := rows.Scan(
+		&user.Id,
+		&user.ExternalId,
+		&user.Username,
+		&user.ChatId,
+		&user.ReminderSchedule,
+		&user.ReminderCount,
+		&user.DigestEnabled,
+		&user.Language,
+		&user.Banned,
+		&user.LastActiveAt,
+		&referredBy,
+		&inboxToken,
+		&feedTokenHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	user.ReferredBy = referredBy.Int64
+	user.InboxToken = inboxToken.String
+	user.FeedTokenHash = feedTokenHash.String
+
+	return &user, nil
+}
@@ -0,0 +1,117 @@
+package deeplink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Action is what opening a link does.
+type Action byte
+
+const (
+	ActionAddArticle Action = 'a'
+	ActionJoinTeam   Action = 't'
+	ActionReferral   Action = 'r'
+)
+
+const (
+	// MaxArg is the longest argument that keeps the payload within 64
+	// characters.
+	MaxArg     = 16
+	macSize    = 12
+	headerSize = 5
+)
+
+var (
+	ErrInvalid = errors.New("invalid link payload")
+	ErrExpired = errors.New("link payload expired")
+)
+
+type Payload struct {
+	Action    Action
+	Arg       []byte
+	ExpiresAt time.Time
+	// Id identifies a signed payload, it is set by Verify.
+	Id string
+}
+
+// Signer signs the payloads of t.me/<bot>?start=<payload> links, so that
+// the bot only acts on links it handed out itself. A payload is the
+// base64url encoding of the action, the expiry time, an action specific
+// argument and a truncated HMAC-SHA256 of all of them, which stays within
+// the 64 characters of [A-Za-z0-9_-] Telegram allows.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+// Sign returns the /start payload for p. The expiry is stored with second
+// precision.
+func (s *Signer) Sign(p Payload) (string, error) {
+	if len(p.Arg) > MaxArg {
+		return "", fmt.Errorf("link argument is %d bytes, at most %d fit", len(p.Arg), MaxArg)
+	}
+
+	data := make([]byte, headerSize, headerSize+len(p.Arg)+macSize)
+	data[0] = byte(p.Action)
+	binary.BigEndian.PutUint32(data[1:headerSize], uint32(p.ExpiresAt.Unix()))
+	data = append(data, p.Arg...)
+	data = append(data, s.mac(data)...)
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Verify checks the signature and the expiry of a /start payload.
+func (s *Signer) Verify(payload string, now time.Time) (*Payload, error) {
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(data) < headerSize+macSize {
+		return nil, ErrInvalid
+	}
+
+	signed, mac := data[:len(data)-macSize], data[len(data)-macSize:]
+	if !hmac.Equal(mac, s.mac(signed)) {
+		return nil, ErrInvalid
+	}
+
+	p := &Payload{
+		Action:    Action(signed[0]),
+		Arg:       signed[headerSize:],
+		ExpiresAt: time.Unix(int64(binary.BigEndian.Uint32(signed[1:headerSize])), 0),
+		Id:        hex.EncodeToString(mac),
+	}
+	if !now.Before(p.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	return p, nil
+}
+
+func (s *Signer) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(data)
+
+	return h.Sum(nil)[:macSize]
+}
+
+// Int64Arg encodes an ID as a link argument.
+func Int64Arg(v int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(v))
+}
+
+// Int64 decodes an argument made by Int64Arg.
+func (p *Payload) Int64() (int64, error) {
+	if len(p.Arg) != 8 {
+		return 0, ErrInvalid
+	}
+
+	return int64(binary.BigEndian.Uint64(p.Arg)), nil
+}
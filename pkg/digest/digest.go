@@ -0,0 +1,135 @@
+package digest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/i18n"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+const (
+	Period        = 7 * 24 * time.Hour
+	oldestLimit   = 3
+	suggestions   = 3
+	finishedLimit = 10
+)
+
+type Digest struct {
+	From        time.Time
+	To          time.Time
+	Finished    []*models.Task
+	Added       int
+	BacklogSize int
+	Oldest      []*models.Task
+	Suggested   []*models.Task
+}
+
+// IsEmpty reports whether there is nothing worth sending, e.g. the user
+// never added anything.
+func (d *Digest) IsEmpty() bool {
+	return len(d.Finished) == 0 && d.Added == 0 && d.BacklogSize == 0
+}
+
+type Generator struct {
+	tasksDao dao.Tasks
+}
+
+func NewGenerator(tasksDao dao.Tasks) *Generator {
+	return &Generator{tasksDao: tasksDao}
+}
+
+func (g *Generator) Generate(user *models.User, now time.Time) (*Digest, error) {
+	from := now.Add(-Period)
+
+	finished, err := g.tasksDao.GetUsersTasksByStatusUpdatedSince(user.Id, models.TaskStatusDone, from)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := g.tasksDao.CountUsersTasksCreatedSince(user.Id, from)
+	if err != nil {
+		return nil, err
+	}
+
+	backlogSize, err := g.tasksDao.CountUsersTasksByStatus(user.Id, models.TaskStatusNew)
+	if err != nil {
+		return nil, err
+	}
+
+	oldest, err := g.tasksDao.GetUsersOldestTasksByStatus(user.Id, models.TaskStatusNew, oldestLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	suggested, err := g.tasksDao.GetUsersRandomTasksByStatus(user.Id, models.TaskStatusNew, suggestions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Digest{
+		From:        from,
+		To:          now,
+		Finished:    finished,
+		Added:       added,
+		BacklogSize: backlogSize,
+		Oldest:      oldest,
+		Suggested:   suggested,
+	}, nil
+}
+
+// Render formats the digest using Telegram's HTML parse mode.
+func Render(d *Digest, catalog *i18n.Catalog, lang string) string {
+	var b strings.Builder
+
+	b.WriteString(catalog.T(lang, "digest.title", i18n.Data{
+		"From": d.From.Format("02.01"),
+		"To":   d.To.Format("02.01"),
+	}))
+	b.WriteString("\n\n")
+
+	b.WriteString(catalog.T(lang, "digest.finished_count", i18n.Data{"Count": len(d.Finished)}))
+	b.WriteString("\n")
+	b.WriteString(catalog.T(lang, "digest.added_count", i18n.Data{"Count": d.Added}))
+	b.WriteString("\n")
+	b.WriteString(catalog.T(lang, "digest.backlog_count", i18n.Data{"Count": d.BacklogSize}))
+	b.WriteString("\n")
+
+	if len(d.Finished) > 0 {
+		b.WriteString("\n" + catalog.T(lang, "digest.finished_title") + "\n")
+		writeTaskList(&b, catalog, lang, d.Finished, finishedLimit, time.Time{})
+	}
+
+	if len(d.Oldest) > 0 {
+		b.WriteString("\n" + catalog.T(lang, "digest.oldest_title") + "\n")
+		writeTaskList(&b, catalog, lang, d.Oldest, oldestLimit, d.To)
+	}
+
+	if len(d.Suggested) > 0 {
+		b.WriteString("\n" + catalog.T(lang, "digest.suggested_title") + "\n")
+		writeTaskList(&b, catalog, lang, d.Suggested, suggestions, time.Time{})
+	}
+
+	return b.String()
+}
+
+// writeTaskList writes a numbered list of links, with the age of every task
+// relative to ageAt unless ageAt is zero.
+func writeTaskList(b *strings.Builder, catalog *i18n.Catalog, lang string, tasks []*models.Task, limit int, ageAt time.Time) {
+	for i, task := range tasks {
+		if i == limit {
+			b.WriteString(catalog.Plural(lang, "digest.more", len(tasks)-limit))
+			b.WriteString("\n")
+			break
+		}
+
+		b.WriteString(fmt.Sprintf("%d. <a href=\"%s\">%s</a>", i+1, html.EscapeString(task.Url), html.EscapeString(task.Url)))
+		if !ageAt.IsZero() {
+			days := int(ageAt.Sub(task.CreatedAt).Hours() / 24)
+			b.WriteString(" <i>(" + catalog.Plural(lang, "digest.age_days", days) + ")</i>")
+		}
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,57 @@
+package errs
+
+import "errors"
+
+// ErrUser is an error that should be shown to the user as is. Key is the
+// message catalog key of the reply and Data holds the template values.
+type ErrUser struct {
+	Key  string
+	Data map[string]any
+	Err  error
+}
+
+func NewErrUser(key string, data map[string]any, err error) *ErrUser {
+	return &ErrUser{
+		Key:  key,
+		Data: data,
+		Err:  err,
+	}
+}
+
+func (e *ErrUser) Error() string {
+	if e.Err != nil {
+		return e.Key + ": " + e.Err.Error()
+	}
+	return e.Key
+}
+
+func (e *ErrUser) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrUser) Is(target error) bool {
+	_, ok := target.(*ErrUser)
+	return ok
+}
+
+// ToUser maps an error returned by a handler to the reply the user gets.
+// It returns false for unexpected errors, which get a generic reply with a
+// reference ID instead.
+func ToUser(err error) (*ErrUser, bool) {
+	var userErr *ErrUser
+	if errors.As(err, &userErr) {
+		return userErr, true
+	}
+
+	var notFinishedErr *ErrNotFinished
+	if errors.As(err, &notFinishedErr) {
+		return NewErrUser("next.unfinished", map[string]any{"Url": notFinishedErr.Task.Url}, err), true
+	}
+
+	var notFoundErr *ErrNotFound
+	if errors.As(err, &notFoundErr) {
+		return NewErrUser("error.not_found", nil, err), true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,53 @@
+package errs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrWithRef attaches a reference ID to an error. The ID is shown to the
+// user and logged with the error, so a bug report can be matched to the
+// log entry.
+type ErrWithRef struct {
+	Ref string
+	Err error
+}
+
+func NewErrWithRef(err error) *ErrWithRef {
+	return &ErrWithRef{
+		Ref: newRef(),
+		Err: err,
+	}
+}
+
+func (e *ErrWithRef) Error() string {
+	return e.Err.Error() + " (ref " + e.Ref + ")"
+}
+
+func (e *ErrWithRef) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrWithRef) Is(target error) bool {
+	_, ok := target.(*ErrWithRef)
+	return ok
+}
+
+// RefOf returns the reference ID attached to the error, if any.
+func RefOf(err error) string {
+	var refErr *ErrWithRef
+	if errors.As(err, &refErr) {
+		return refErr.Ref
+	}
+	return ""
+}
+
+func newRef() string {
+	buf := make([]byte, 4)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,282 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// Export is everything stored about a user, in the shape it is handed out
+// by /my_data.
+type Export struct {
+	ExportedAt time.Time `json:"exported_at"`
+	User       User      `json:"user"`
+	Tasks      []Task    `json:"tasks"`
+	// SharesSent are the links and offers the user created, SharesReceived
+	// the articles offered to them.
+	SharesSent     []Share  `json:"shares_sent"`
+	SharesReceived []Share  `json:"shares_received"`
+	Team           Team     `json:"team"`
+	Referral       Referral `json:"referral"`
+	// ApiToken is null if the user has no API token.
+	ApiToken *ApiToken `json:"api_token"`
+	Feeds    []Feed    `json:"feeds"`
+}
+
+type User struct {
+	TelegramId       string `json:"telegram_id"`
+	Username         string `json:"username"`
+	ChatId           int64  `json:"chat_id"`
+	ReminderSchedule string `json:"reminder_schedule"`
+	ReminderCount    int    `json:"reminder_count"`
+	DigestEnabled    bool   `json:"digest_enabled"`
+	Language         string `json:"language"`
+	// HasInboxAddress tells if the user has an /email address, the address
+	// itself is left out like the API token.
+	HasInboxAddress bool      `json:"has_inbox_address"`
+	LastActiveAt    time.Time `json:"last_active_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type Task struct {
+	Url    string   `json:"url"`
+	Status string   `json:"status"`
+	Tags   []string `json:"tags"`
+	Notes  []Note   `json:"notes"`
+	// Rating is zero if the article wasn't rated.
+	Rating    int       `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Note struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Share struct {
+	// From is how the sender was shown, Link tells links anyone could open
+	// from articles offered to one user.
+	From      string    `json:"from"`
+	Link      bool      `json:"link"`
+	Url       string    `json:"url"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Team lists the other users by username, the members of the user's team
+// and the owners of the teams the user joined.
+type Team struct {
+	Members []string `json:"members"`
+	Joined  []string `json:"joined"`
+}
+
+type Referral struct {
+	// ReferredBy is the username of who invited the user, empty if nobody
+	// did or they have no username.
+	ReferredBy string `json:"referred_by"`
+	Invited    int    `json:"invited"`
+}
+
+// ApiToken only tells when the token was created and used, the token itself
+// isn't stored.
+type ApiToken struct {
+	CreatedAt time.Time `json:"created_at"`
+	// LastUsedAt is null if the token was never used.
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type Feed struct {
+	Url       string    `json:"url"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Generator struct {
+	usersDao  dao.Users
+	tasksDao  dao.Tasks
+	notesDao  dao.Notes
+	sharesDao dao.Shares
+	teamsDao  dao.Teams
+	tokensDao dao.Tokens
+	feedsDao  dao.Feeds
+}
+
+func NewGenerator(usersDao dao.Users, tasksDao dao.Tasks, notesDao dao.Notes, sharesDao dao.Shares, teamsDao dao.Teams, tokensDao dao.Tokens, feedsDao dao.Feeds) *Generator {
+	return &Generator{
+		usersDao:  usersDao,
+		tasksDao:  tasksDao,
+		notesDao:  notesDao,
+		sharesDao: sharesDao,
+		teamsDao:  teamsDao,
+		tokensDao: tokensDao,
+		feedsDao:  feedsDao,
+	}
+}
+
+func (g *Generator) Generate(user *models.User, now time.Time) (*Export, error) {
+	tasks, err := g.tasksDao.GetUsersTasks(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := g.notesDao.GetUsersNotes(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := g.sharesDao.GetUsersShares(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := g.teamsDao.GetTeamMembers(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	joined, err := g.teamsDao.GetJoinedTeams(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	referral, err := g.referral(user)
+	if err != nil {
+		return nil, err
+	}
+
+	apiToken, err := g.apiToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, err := g.feedsDao.GetUsersFeeds(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Export{
+		ExportedAt: now.UTC(),
+		User: User{
+			TelegramId:       user.ExternalId,
+			Username:         user.Username,
+			ChatId:           user.ChatId,
+			ReminderSchedule: user.ReminderSchedule,
+			ReminderCount:    user.ReminderCount,
+			DigestEnabled:    user.DigestEnabled,
+			Language:         user.Language,
+			HasInboxAddress:  user.InboxToken != "",
+			LastActiveAt:     user.LastActiveAt,
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+		},
+		Tasks:          make([]Task, 0, len(tasks)),
+		SharesSent:     []Share{},
+		SharesReceived: []Share{},
+		Team: Team{
+			Members: usernames(members),
+			Joined:  usernames(joined),
+		},
+		Referral: referral,
+		ApiToken: apiToken,
+		Feeds:    make([]Feed, 0, len(feeds)),
+	}
+	for _, task := range tasks {
+		t := Task{
+			Url:       task.Url,
+			Status:    task.Status,
+			Tags:      task.Tags,
+			Notes:     make([]Note, 0, len(notes[task.Id])),
+			Rating:    task.Rating,
+			CreatedAt: task.CreatedAt,
+			UpdatedAt: task.UpdatedAt,
+		}
+		if t.Tags == nil {
+			t.Tags = []string{}
+		}
+		for _, note := range notes[task.Id] {
+			t.Notes = append(t.Notes, Note{Text: note.Text, CreatedAt: note.CreatedAt})
+		}
+		e.Tasks = append(e.Tasks, t)
+	}
+	for _, feed := range feeds {
+		e.Feeds = append(e.Feeds, Feed{Url: feed.Url, Title: feed.Title, CreatedAt: feed.CreatedAt})
+	}
+	for _, share := range shares {
+		s := Share{
+			From:      share.FromName,
+			Link:      share.RecipientId == 0,
+			Url:       share.Url,
+			Tags:      share.Tags,
+			CreatedAt: share.CreatedAt,
+		}
+		if s.Tags == nil {
+			s.Tags = []string{}
+		}
+		if share.UserId == user.Id {
+			e.SharesSent = append(e.SharesSent, s)
+		} else {
+			e.SharesReceived = append(e.SharesReceived, s)
+		}
+	}
+
+	return e, nil
+}
+
+func (g *Generator) referral(user *models.User) (Referral, error) {
+	invited, err := g.usersDao.CountUsersReferredBy(user.Id)
+	if err != nil {
+		return Referral{}, err
+	}
+
+	r := Referral{Invited: invited}
+	if user.ReferredBy == 0 {
+		return r, nil
+	}
+
+	// The referrer may have deleted their account since.
+	referrer, err := g.usersDao.GetUserById(user.ReferredBy)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return r, nil
+	}
+	if err != nil {
+		return Referral{}, err
+	}
+	r.ReferredBy = referrer.Username
+
+	return r, nil
+}
+
+func (g *Generator) apiToken(user *models.User) (*ApiToken, error) {
+	token, err := g.tokensDao.GetUserToken(user.Id)
+	if errors.Is(err, &errs.ErrNotFound{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ApiToken{CreatedAt: token.CreatedAt}
+	if !token.LastUsedAt.IsZero() {
+		t.LastUsedAt = &token.LastUsedAt
+	}
+
+	return t, nil
+}
+
+func usernames(users []*models.User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+
+	return names
+}
+
+func (e *Export) JSON() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
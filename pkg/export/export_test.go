@@ -0,0 +1,200 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+const testUserId = 1
+
+var testTime = time.Date(2023, 6, 5, 10, 0, 0, 0, time.UTC)
+
+type fakeTasks struct {
+	dao.Tasks
+}
+
+func (fakeTasks) GetUsersTasks(int64) ([]*models.Task, error) {
+	return []*models.Task{
+		{Id: 10, UserId: testUserId, Url: "https://example.com/a", Status: models.TaskStatusDone, Tags: []string{"go"}, Rating: 4, CreatedAt: testTime, UpdatedAt: testTime},
+		{Id: 11, UserId: testUserId, Url: "https://example.com/b", Status: models.TaskStatusNew, CreatedAt: testTime, UpdatedAt: testTime},
+	}, nil
+}
+
+type fakeNotes struct {
+	dao.Notes
+}
+
+func (fakeNotes) GetUsersNotes(int64) (map[int64][]*models.Note, error) {
+	return map[int64][]*models.Note{
+		10: {{Id: 1, TaskId: 10, Text: "worth it", CreatedAt: testTime}},
+	}, nil
+}
+
+type fakeShares struct {
+	dao.Shares
+}
+
+func (fakeShares) GetUsersShares(int64) ([]*models.Share, error) {
+	return []*models.Share{
+		{Token: "a", UserId: testUserId, FromName: "@alice", Url: "https://example.com/link", CreatedAt: testTime},
+		{Token: "b", UserId: testUserId, RecipientId: 2, FromName: "@alice", Url: "https://example.com/offer", Tags: []string{"go"}, CreatedAt: testTime},
+		{Token: "c", UserId: 2, RecipientId: testUserId, FromName: "@bob", Url: "https://example.com/received", CreatedAt: testTime},
+	}, nil
+}
+
+type fakeTeams struct {
+	dao.Teams
+}
+
+func (fakeTeams) GetTeamMembers(int64) ([]*models.User, error) {
+	return []*models.User{{Id: 2, Username: "bob"}, {Id: 3, Username: "carol"}}, nil
+}
+
+func (fakeTeams) GetJoinedTeams(int64) ([]*models.User, error) {
+	return []*models.User{{Id: 4, Username: "dave"}}, nil
+}
+
+type fakeUsers struct {
+	dao.Users
+}
+
+func (fakeUsers) CountUsersReferredBy(int64) (int, error) {
+	return 2, nil
+}
+
+func (fakeUsers) GetUserById(id int64) (*models.User, error) {
+	if id != 4 {
+		return nil, errs.NewErrNotFound("User", "id", strconv.FormatInt(id, 10))
+	}
+
+	return &models.User{Id: 4, Username: "dave"}, nil
+}
+
+type fakeTokens struct {
+	dao.Tokens
+}
+
+func (fakeTokens) GetUserToken(userId int64) (*models.Token, error) {
+	return &models.Token{UserId: userId, CreatedAt: testTime}, nil
+}
+
+type fakeFeeds struct {
+	dao.Feeds
+}
+
+func (fakeFeeds) GetUsersFeeds(userId int64) ([]*models.Feed, error) {
+	return []*models.Feed{
+		{Id: 1, UserId: userId, Url: "https://example.com/feed.xml", Title: "Example", ETag: `"v1"`, CreatedAt: testTime},
+	}, nil
+}
+
+func newTestGenerator() *Generator {
+	return NewGenerator(fakeUsers{}, fakeTasks{}, fakeNotes{}, fakeShares{}, fakeTeams{}, fakeTokens{}, fakeFeeds{})
+}
+
+func testUser() *models.User {
+	return &models.User{
+		Id:               testUserId,
+		ExternalId:       "42",
+		Username:         "alice",
+		ChatId:           42,
+		ReminderSchedule: "0 17 * * *",
+		ReminderCount:    1,
+		Language:         "en",
+		ReferredBy:       4,
+		InboxToken:       "secret",
+		LastActiveAt:     testTime,
+		CreatedAt:        testTime,
+		UpdatedAt:        testTime,
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	e, err := newTestGenerator().Generate(testUser(), testTime)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	wantTasks := []Task{
+		{Url: "https://example.com/a", Status: models.TaskStatusDone, Tags: []string{"go"}, Notes: []Note{{Text: "worth it", CreatedAt: testTime}}, Rating: 4, CreatedAt: testTime, UpdatedAt: testTime},
+		{Url: "https://example.com/b", Status: models.TaskStatusNew, Tags: []string{}, Notes: []Note{}, CreatedAt: testTime, UpdatedAt: testTime},
+	}
+	if !reflect.DeepEqual(e.Tasks, wantTasks) {
+		t.Errorf("tasks:\ngot  %+v\nwant %+v", e.Tasks, wantTasks)
+	}
+	wantSent := []Share{
+		{From: "@alice", Link: true, Url: "https://example.com/link", Tags: []string{}, CreatedAt: testTime},
+		{From: "@alice", Url: "https://example.com/offer", Tags: []string{"go"}, CreatedAt: testTime},
+	}
+	if !reflect.DeepEqual(e.SharesSent, wantSent) {
+		t.Errorf("shares sent:\ngot  %+v\nwant %+v", e.SharesSent, wantSent)
+	}
+	wantReceived := []Share{
+		{From: "@bob", Url: "https://example.com/received", Tags: []string{}, CreatedAt: testTime},
+	}
+	if !reflect.DeepEqual(e.SharesReceived, wantReceived) {
+		t.Errorf("shares received:\ngot  %+v\nwant %+v", e.SharesReceived, wantReceived)
+	}
+
+	wantTeam := Team{Members: []string{"bob", "carol"}, Joined: []string{"dave"}}
+	if !reflect.DeepEqual(e.Team, wantTeam) {
+		t.Errorf("team: got %+v, want %+v", e.Team, wantTeam)
+	}
+	if want := (Referral{ReferredBy: "dave", Invited: 2}); e.Referral != want {
+		t.Errorf("referral: got %+v, want %+v", e.Referral, want)
+	}
+
+	if e.ApiToken == nil || !e.ApiToken.CreatedAt.Equal(testTime) || e.ApiToken.LastUsedAt != nil {
+		t.Errorf("api token: got %+v, want created at %s and never used", e.ApiToken, testTime)
+	}
+
+	wantFeeds := []Feed{{Url: "https://example.com/feed.xml", Title: "Example", CreatedAt: testTime}}
+	if !reflect.DeepEqual(e.Feeds, wantFeeds) {
+		t.Errorf("feeds: got %+v, want %+v", e.Feeds, wantFeeds)
+	}
+
+	if e.User.TelegramId != "42" || e.User.Username != "alice" || !e.User.HasInboxAddress {
+		t.Errorf("user: got %+v", e.User)
+	}
+}
+
+// Every part of the export is in the JSON, empty lists as [] rather than
+// null.
+func TestJSON(t *testing.T) {
+	e, err := newTestGenerator().Generate(testUser(), testTime)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	body, err := e.JSON()
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+
+	if bytes.Contains(body, []byte(testUser().InboxToken)) {
+		t.Errorf("the inbox token is in the export")
+	}
+
+	var decoded map[string]any
+	err = json.Unmarshal(body, &decoded)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, key := range []string{"exported_at", "user", "tasks", "shares_sent", "shares_received", "team", "referral", "api_token", "feeds"} {
+		if decoded[key] == nil {
+			t.Errorf("%s is missing or null", key)
+		}
+	}
+
+	task := decoded["tasks"].([]any)[0].(map[string]any)
+	if task["rating"] != float64(4) {
+		t.Errorf("rating: got %v, want 4", task["rating"])
+	}
+}
@@ -0,0 +1,82 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"tg_bot/pkg/netguard"
+	"time"
+)
+
+const userAgent = "read_that_bot feed reader"
+
+// ErrUnavailable is returned by Poller.Subscribe if the feed couldn't be
+// downloaded.
+var ErrUnavailable = errors.New("feed could not be downloaded")
+
+// Response is a downloaded feed. Feed is nil if the feed didn't change
+// since the response the validators came from.
+type Response struct {
+	Feed         *Feed
+	ETag         string
+	LastModified string
+}
+
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// NewFetcher returns a fetcher that only connects to public addresses, so
+// feed URLs can't reach services in the network the bot runs in.
+func NewFetcher(timeout time.Duration, maxSize int64) *Fetcher {
+	return &Fetcher{
+		client:  netguard.NewClient(timeout),
+		maxSize: maxSize,
+	}
+}
+
+// Fetch downloads and parses the feed. The ETag and Last-Modified of the
+// previous response make it a conditional request, pass empty strings to
+// always get the feed.
+func (f *Fetcher) Fetch(ctx context.Context, feedUrl, etag, lastModified string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.1")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &Response{ETag: etag, LastModified: lastModified}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Redirects are followed, links are relative to where the feed is now.
+	feed, err := Parse(io.LimitReader(resp.Body, f.maxSize), resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Feed:         feed,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
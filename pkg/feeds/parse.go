@@ -0,0 +1,276 @@
+package feeds
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrNotFeed = errors.New("not an RSS or Atom feed")
+
+// dateLayouts are the date formats seen in the wild. RSS asks for RFC 822
+// dates, Atom for RFC 3339 ones.
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+type Feed struct {
+	Title string
+	// Entries are sorted newest first, entries without a date come last.
+	Entries []Entry
+}
+
+type Entry struct {
+	// Id is the guid or id of the entry, if the feed has one.
+	Id        string
+	Url       string
+	Title     string
+	Published time.Time
+}
+
+// Key identifies the entry among the entries of its feed. It is short, so
+// the keys of a whole feed can be stored with it.
+func (e Entry) Key() string {
+	id := e.Id
+	if id == "" {
+		id = e.Url
+	}
+	sum := sha1.Sum([]byte(id))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// xmlFeed covers RSS 2.0, RSS 1.0 and Atom. Fields are matched by local
+// name, so namespaced elements like atom:link in RSS end up in the same
+// slices.
+type xmlFeed struct {
+	XMLName xml.Name
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []xmlItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 puts the items next to the channel.
+	Items   []xmlItem  `xml:"item"`
+	Title   string     `xml:"title"`
+	Entries []xmlEntry `xml:"entry"`
+}
+
+type xmlItem struct {
+	Title   string    `xml:"title"`
+	Links   []xmlLink `xml:"link"`
+	GUID    string    `xml:"guid"`
+	PubDate string    `xml:"pubDate"`
+	Date    string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type xmlEntry struct {
+	Title     string    `xml:"title"`
+	Id        string    `xml:"id"`
+	Links     []xmlLink `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+}
+
+// xmlLink is an RSS <link>url</link> or an Atom <link href="url"/>.
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Parse reads an RSS or Atom feed. Relative links are resolved against
+// base, entries without an http(s) link are left out.
+func Parse(r io.Reader, base *url.URL) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+
+	var doc xmlFeed
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFeed, err)
+	}
+
+	feed := &Feed{}
+	switch doc.XMLName.Local {
+	case "rss":
+		feed.Title = doc.Channel.Title
+		feed.Entries = rssEntries(doc.Channel.Items, base)
+	case "RDF":
+		feed.Title = doc.Channel.Title
+		feed.Entries = rssEntries(doc.Items, base)
+	case "feed":
+		feed.Title = doc.Title
+		feed.Entries = atomEntries(doc.Entries, base)
+	default:
+		return nil, ErrNotFeed
+	}
+	feed.Title = strings.TrimSpace(feed.Title)
+
+	sort.SliceStable(feed.Entries, func(i, j int) bool {
+		return feed.Entries[i].Published.After(feed.Entries[j].Published)
+	})
+
+	return feed, nil
+}
+
+func rssEntries(items []xmlItem, base *url.URL) []Entry {
+	var entries []Entry
+	for _, item := range items {
+		var link string
+		for _, l := range item.Links {
+			link = strings.TrimSpace(l.Text)
+			if link == "" && (l.Rel == "" || l.Rel == "alternate") {
+				link = strings.TrimSpace(l.Href)
+			}
+			if link != "" {
+				break
+			}
+		}
+		// Without a link, a guid that isn't marked as a permalink is often
+		// the URL anyway.
+		if link == "" {
+			link = strings.TrimSpace(item.GUID)
+		}
+
+		link = resolve(base, link)
+		if link == "" {
+			continue
+		}
+
+		published := parseDate(item.PubDate)
+		if published.IsZero() {
+			published = parseDate(item.Date)
+		}
+
+		entries = append(entries, Entry{
+			Id:        strings.TrimSpace(item.GUID),
+			Url:       link,
+			Title:     strings.TrimSpace(item.Title),
+			Published: published,
+		})
+	}
+
+	return entries
+}
+
+func atomEntries(xmlEntries []xmlEntry, base *url.URL) []Entry {
+	var entries []Entry
+	for _, entry := range xmlEntries {
+		var link string
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+
+		link = resolve(base, link)
+		if link == "" {
+			continue
+		}
+
+		published := parseDate(entry.Published)
+		if published.IsZero() {
+			published = parseDate(entry.Updated)
+		}
+
+		entries = append(entries, Entry{
+			Id:        strings.TrimSpace(entry.Id),
+			Url:       link,
+			Title:     strings.TrimSpace(entry.Title),
+			Published: published,
+		})
+	}
+
+	return entries
+}
+
+// resolve returns the absolute URL of the link, or "" if it isn't http(s).
+func resolve(base *url.URL, link string) string {
+	if link == "" {
+		return ""
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	return u.String()
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// charsetReader supports the charsets other than UTF-8 that feeds still
+// declare. Windows-1252 is read as Latin-1, which only differs in
+// punctuation.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		return &latin1Reader{r: input}, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.buf) == 0 {
+		// Every byte becomes at most two bytes of UTF-8.
+		raw := make([]byte, (len(p)+1)/2)
+		n, err := l.r.Read(raw)
+		for _, c := range raw[:n] {
+			l.buf = utf8.AppendRune(l.buf, rune(c))
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+
+	return n, nil
+}
@@ -0,0 +1,244 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	pollConcurrency = 4
+	// maxSeenEntries keeps huge feeds from outgrowing the column the keys
+	// are stored in.
+	maxSeenEntries = 1000
+	// Longer values don't fit into their columns and are dropped, which at
+	// worst makes the next request unconditional.
+	maxTitle     = 255
+	maxValidator = 255
+)
+
+// Poller adds the new entries of the subscribed feeds to the subscribers'
+// lists. An entry is new if it wasn't in the feed at the last check and its
+// URL isn't in the list yet. At most maxEntries are added per check, the
+// newest first, the rest are skipped for good.
+type Poller struct {
+	usersDao   dao.Users
+	feedsDao   dao.Feeds
+	tasksDao   dao.Tasks
+	fetcher    *Fetcher
+	interval   time.Duration
+	maxEntries int
+	batchSize  uint64
+}
+
+func NewPoller(usersDao dao.Users, feedsDao dao.Feeds, tasksDao dao.Tasks, fetcher *Fetcher, interval time.Duration, maxEntries int, batchSize uint64) *Poller {
+	return &Poller{
+		usersDao:   usersDao,
+		feedsDao:   feedsDao,
+		tasksDao:   tasksDao,
+		fetcher:    fetcher,
+		interval:   interval,
+		maxEntries: maxEntries,
+		batchSize:  batchSize,
+	}
+}
+
+// Subscribe downloads the feed to make sure it is one, stores the
+// subscription and adds its newest entries. It returns the stored feed and the
+// number of added articles. Errors of the download wrap ErrNotFeed or
+// ErrUnavailable.
+func (p *Poller) Subscribe(ctx context.Context, userId int64, feedUrl string, now time.Time) (*models.Feed, int, error) {
+	resp, err := p.fetcher.Fetch(ctx, feedUrl, "", "")
+	if err != nil {
+		if !errors.Is(err, ErrNotFeed) {
+			err = fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return nil, 0, err
+	}
+
+	// The feed is stored first, so nothing is added for a subscription that
+	// can't be saved. Its validators and seen entries are only saved once
+	// the entries are added, if that fails the next check adds them.
+	feed, err := p.feedsDao.InsertFeed(&models.Feed{UserId: userId, Url: feedUrl})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	added, err := p.addEntries(feed, resp.Feed)
+	if err != nil {
+		return nil, added, err
+	}
+
+	setResponse(feed, resp)
+	feed.CheckedAt = now
+	err = p.feedsDao.UpdateFeed(feed)
+	if err != nil {
+		return nil, added, err
+	}
+
+	return feed, added, nil
+}
+
+// Run checks one batch of feeds that are due and returns how many articles
+// were added.
+func (p *Poller) Run(ctx context.Context, now time.Time) (int, error) {
+	feeds, err := p.feedsDao.GetFeedsToPoll(now.Add(-p.interval), p.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	total := 0
+	sem := make(chan struct{}, pollConcurrency)
+	for _, feed := range feeds {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(feed *models.Feed) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			added, err := p.Poll(ctx, feed, now)
+			if err != nil {
+				logger.Get().Warn("Could not poll feed", zap.Int64("feed_id", feed.Id), zap.String("url", feed.Url), zap.Error(err))
+			}
+
+			mu.Lock()
+			total += added
+			mu.Unlock()
+		}(feed)
+	}
+	wg.Wait()
+
+	return total, nil
+}
+
+// Poll checks the feed for new entries and returns how many were added. A
+// failed download counts as a check, so a broken feed waits for the next
+// interval like any other.
+func (p *Poller) Poll(ctx context.Context, feed *models.Feed, now time.Time) (int, error) {
+	feed.CheckedAt = now
+
+	resp, err := p.fetcher.Fetch(ctx, feed.Url, feed.ETag, feed.LastModified)
+	if err != nil {
+		metrics.FeedPolls.WithLabelValues(metrics.ResultError).Inc()
+		feed.Failures++
+		return 0, errors.Join(err, p.feedsDao.UpdateFeed(feed))
+	}
+
+	feed.Failures = 0
+	if resp.Feed == nil {
+		metrics.FeedPolls.WithLabelValues(metrics.FeedNotModified).Inc()
+		return 0, p.feedsDao.UpdateFeed(feed)
+	}
+
+	metrics.FeedPolls.WithLabelValues(metrics.ResultOk).Inc()
+	setResponse(feed, resp)
+
+	// After a failure the check isn't recorded and the whole feed is tried
+	// again with the next batch, the entries added so far are skipped then
+	// as they are in the list.
+	added, err := p.addEntries(feed, resp.Feed)
+	if err != nil {
+		return added, err
+	}
+
+	// Articles coming in count as activity, retention would otherwise
+	// delete users who only read their subscriptions.
+	if added > 0 {
+		err = p.usersDao.UpdateUserLastActive(feed.UserId)
+		if err != nil {
+			return added, err
+		}
+	}
+
+	return added, p.feedsDao.UpdateFeed(feed)
+}
+
+func setResponse(feed *models.Feed, resp *Response) {
+	if resp.Feed.Title != "" {
+		feed.Title = truncate(resp.Feed.Title, maxTitle)
+	}
+
+	feed.ETag = resp.ETag
+	if len(feed.ETag) > maxValidator {
+		feed.ETag = ""
+	}
+	feed.LastModified = resp.LastModified
+	if len(feed.LastModified) > maxValidator {
+		feed.LastModified = ""
+	}
+}
+
+// addEntries adds the new entries and, if all went well, replaces the seen
+// ones with those in the feed now.
+func (p *Poller) addEntries(feed *models.Feed, parsed *Feed) (int, error) {
+	seen := make(map[string]bool, len(feed.SeenEntries))
+	for _, key := range feed.SeenEntries {
+		seen[key] = true
+	}
+
+	var keys []string
+	var added int
+	for _, entry := range parsed.Entries {
+		key := entry.Key()
+		if len(keys) < maxSeenEntries {
+			keys = append(keys, key)
+		}
+		if seen[key] || added == p.maxEntries || utf8.RuneCountInString(entry.Url) > models.MaxUrlLength {
+			continue
+		}
+
+		isNew, err := p.addTask(feed.UserId, entry.Url)
+		if err != nil {
+			return added, err
+		}
+		if isNew {
+			added++
+		}
+	}
+	feed.SeenEntries = keys
+
+	return added, nil
+}
+
+func (p *Poller) addTask(userId int64, link string) (bool, error) {
+	_, err := p.tasksDao.GetUsersTaskByUrl(userId, link)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, &errs.ErrNotFound{}) {
+		return false, err
+	}
+
+	_, err = p.tasksDao.InsertTask(&models.Task{
+		UserId: userId,
+		Url:    link,
+		Status: models.TaskStatusNew,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+
+	return s
+}
@@ -0,0 +1,323 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+const (
+	testUserId  = 7
+	testModTime = "Mon, 05 Jun 2023 10:00:00 GMT"
+)
+
+type fakeTasks struct {
+	dao.Tasks
+
+	mu    sync.Mutex
+	tasks []*models.Task
+}
+
+func (f *fakeTasks) GetUsersTaskByUrl(userId int64, url string) (*models.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, task := range f.tasks {
+		if task.UserId == userId && task.Url == url {
+			return task, nil
+		}
+	}
+
+	return nil, errs.NewErrNotFound("Task", "url", url)
+}
+
+func (f *fakeTasks) InsertTask(task *models.Task) (*models.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task.Id = int64(len(f.tasks) + 1)
+	f.tasks = append(f.tasks, task)
+
+	return task, nil
+}
+
+func (f *fakeTasks) urls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var urls []string
+	for _, task := range f.tasks {
+		urls = append(urls, task.Url)
+	}
+
+	return urls
+}
+
+type fakeFeeds struct {
+	dao.Feeds
+	tasks *fakeTasks
+	// tasksOnInsert is the number of tasks when the feed was inserted.
+	tasksOnInsert int
+	updates       int
+	saved         models.Feed
+}
+
+func (f *fakeFeeds) InsertFeed(feed *models.Feed) (*models.Feed, error) {
+	f.tasksOnInsert = len(f.tasks.urls())
+	feed.Id = 1
+	f.saved = *feed
+
+	return feed, nil
+}
+
+func (f *fakeFeeds) UpdateFeed(feed *models.Feed) error {
+	f.updates++
+	f.saved = *feed
+
+	return nil
+}
+
+type fakeUsers struct {
+	dao.Users
+	active int
+}
+
+func (f *fakeUsers) UpdateUserLastActive(int64) error {
+	f.active++
+
+	return nil
+}
+
+// feedServer serves an RSS feed of the items in the order given, the first
+// is the newest. Requests with the current validators get 304.
+type feedServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	items       []string
+	etag        string
+	notModified int
+}
+
+func newFeedServer(t *testing.T, etag string, items ...string) *feedServer {
+	s := &feedServer{items: items, etag: etag}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *feedServer) setItems(items ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = items
+	s.etag += "+"
+}
+
+func (s *feedServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.etag != "" {
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+	} else {
+		if r.Header.Get("If-Modified-Since") == testModTime {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", testModTime)
+	}
+
+	published := time.Date(2023, 6, 5, 10, 0, 0, 0, time.UTC)
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Test feed</title>`)
+	for i, item := range s.items {
+		fmt.Fprintf(&body, `<item><guid>%s</guid><link>https://example.com/%s</link><pubDate>%s</pubDate></item>`,
+			item, item, published.Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+	}
+	body.WriteString(`</channel></rss>`)
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	_, _ = w.Write([]byte(body.String()))
+}
+
+// newTestPoller skips the public address guard of NewFetcher, test servers
+// listen on loopback.
+func newTestPoller(maxEntries int) (*Poller, *fakeTasks, *fakeFeeds, *fakeUsers) {
+	tasksDao := &fakeTasks{}
+	feedsDao := &fakeFeeds{tasks: tasksDao}
+	usersDao := &fakeUsers{}
+	fetcher := &Fetcher{client: &http.Client{Timeout: time.Second}, maxSize: 1 << 20}
+
+	return NewPoller(usersDao, feedsDao, tasksDao, fetcher, time.Hour, maxEntries, 100), tasksDao, feedsDao, usersDao
+}
+
+func urlsOf(items ...string) string {
+	var urls []string
+	for _, item := range items {
+		urls = append(urls, "https://example.com/"+item)
+	}
+
+	return fmt.Sprint(urls)
+}
+
+func TestSubscribeAddsNewestEntriesUpToTheCap(t *testing.T) {
+	server := newFeedServer(t, `"v1"`, "e", "d", "c", "b", "a")
+	poller, tasksDao, feedsDao, _ := newTestPoller(3)
+
+	feed, added, err := poller.Subscribe(context.Background(), testUserId, server.URL, time.Now())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if added != 3 {
+		t.Errorf("added: got %d, want 3", added)
+	}
+	if got, want := fmt.Sprint(tasksDao.urls()), urlsOf("e", "d", "c"); got != want {
+		t.Errorf("tasks: got %s, want %s", got, want)
+	}
+	if feed.Title != "Test feed" || feed.ETag != `"v1"` || len(feed.SeenEntries) != 5 {
+		t.Errorf("feed: got title %q, etag %q, %d seen entries", feed.Title, feed.ETag, len(feed.SeenEntries))
+	}
+
+	// The feed is stored before the entries are added, its validators and
+	// seen entries after.
+	if feedsDao.tasksOnInsert != 0 {
+		t.Errorf("the feed was stored after %d tasks were added", feedsDao.tasksOnInsert)
+	}
+	if feedsDao.saved.ETag != `"v1"` || len(feedsDao.saved.SeenEntries) != 5 || feedsDao.saved.CheckedAt.IsZero() {
+		t.Errorf("saved feed: got etag %q, %d seen entries, checked at %s", feedsDao.saved.ETag, len(feedsDao.saved.SeenEntries), feedsDao.saved.CheckedAt)
+	}
+}
+
+func TestPollNotModified(t *testing.T) {
+	tests := []struct {
+		name string
+		etag string
+	}{
+		{"etag", `"v1"`},
+		{"last modified", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFeedServer(t, test.etag, "b", "a")
+			poller, tasksDao, feedsDao, _ := newTestPoller(5)
+
+			feed, _, err := poller.Subscribe(context.Background(), testUserId, server.URL, time.Now())
+			if err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+
+			feed.Failures = 2
+			updates := feedsDao.updates
+			added, err := poller.Poll(context.Background(), feed, time.Now())
+			if err != nil {
+				t.Fatalf("poll: %v", err)
+			}
+
+			if server.notModified != 1 {
+				t.Errorf("got %d conditional hits, want 1", server.notModified)
+			}
+			if added != 0 || len(tasksDao.urls()) != 2 {
+				t.Errorf("added %d, %d tasks in total, want 0 and 2", added, len(tasksDao.urls()))
+			}
+			if feed.Failures != 0 || feedsDao.updates-updates != 1 {
+				t.Errorf("got %d failures and %d updates, want 0 and 1", feed.Failures, feedsDao.updates-updates)
+			}
+			if feed.ETag != test.etag || (test.etag == "" && feed.LastModified != testModTime) {
+				t.Errorf("validators lost: etag %q, last modified %q", feed.ETag, feed.LastModified)
+			}
+		})
+	}
+}
+
+func TestPollAddsOnlyNewEntries(t *testing.T) {
+	server := newFeedServer(t, `"v1"`, "c", "b", "a")
+	poller, tasksDao, _, usersDao := newTestPoller(2)
+
+	feed, added, err := poller.Subscribe(context.Background(), testUserId, server.URL, time.Now())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if added != 2 {
+		t.Fatalf("added on subscribe: got %d, want 2", added)
+	}
+
+	// "a" was over the cap and is skipped for good, "x" is already in the
+	// list from elsewhere.
+	_, _ = tasksDao.InsertTask(&models.Task{UserId: testUserId, Url: "https://example.com/x"})
+	server.setItems("e", "x", "d", "c", "b", "a")
+
+	added, err = poller.Poll(context.Background(), feed, time.Now())
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if added != 2 {
+		t.Errorf("added: got %d, want 2", added)
+	}
+	if got, want := fmt.Sprint(tasksDao.urls()), urlsOf("c", "b", "x", "e", "d"); got != want {
+		t.Errorf("tasks: got %s, want %s", got, want)
+	}
+	if usersDao.active != 1 {
+		t.Errorf("last activity updated %d times, want 1", usersDao.active)
+	}
+
+	// Nothing changed but the validator, nothing is added twice.
+	server.setItems("e", "x", "d", "c", "b", "a")
+	added, err = poller.Poll(context.Background(), feed, time.Now())
+	if err != nil {
+		t.Fatalf("poll again: %v", err)
+	}
+	if added != 0 {
+		t.Errorf("added on the second poll: got %d, want 0", added)
+	}
+}
+
+func TestPollCapsEntriesPerCheck(t *testing.T) {
+	server := newFeedServer(t, `"v1"`, "a")
+	poller, tasksDao, _, _ := newTestPoller(3)
+
+	feed, _, err := poller.Subscribe(context.Background(), testUserId, server.URL, time.Now())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	server.setItems("f", "e", "d", "c", "b", "a")
+	added, err := poller.Poll(context.Background(), feed, time.Now())
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if added != 3 {
+		t.Errorf("added: got %d, want 3", added)
+	}
+	if got, want := fmt.Sprint(tasksDao.urls()), urlsOf("a", "f", "e", "d"); got != want {
+		t.Errorf("tasks: got %s, want %s", got, want)
+	}
+}
+
+func TestNewFetcherRefusesPrivateAddresses(t *testing.T) {
+	server := newFeedServer(t, `"v1"`, "a")
+
+	_, err := NewFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL, "", "")
+	if err == nil {
+		t.Errorf("fetched a feed from %s", server.URL)
+	}
+}
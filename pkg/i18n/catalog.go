@@ -0,0 +1,207 @@
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed locales/*.json
+var localesFs embed.FS
+
+const DefaultLocale = "en"
+
+// Data holds the values available to message templates, e.g. {{.Url}}.
+type Data map[string]any
+
+// message is either a single template or a set of plural forms keyed by
+// plural category ("one", "few", "many", "other").
+type message struct {
+	text  *template.Template
+	forms map[string]*template.Template
+}
+
+type Catalog struct {
+	locales map[string]map[string]*message
+}
+
+// Load parses all locale files embedded into the binary. Every locale file
+// is named after its language code, e.g. locales/en.json.
+func Load() (*Catalog, error) {
+	entries, err := localesFs.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{locales: make(map[string]map[string]*message)}
+	for _, entry := range entries {
+		content, err := localesFs.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		locale := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		messages, err := parseLocale(locale, content)
+		if err != nil {
+			return nil, err
+		}
+		c.locales[locale] = messages
+	}
+
+	if _, ok := c.locales[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("default locale %q is missing", DefaultLocale)
+	}
+
+	return c, nil
+}
+
+func parseLocale(locale string, content []byte) (map[string]*message, error) {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(content, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("locale %s: %w", locale, err)
+	}
+
+	messages := make(map[string]*message, len(raw))
+	for key, value := range raw {
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			tmpl, err := template.New(key).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("locale %s, message %s: %w", locale, key, err)
+			}
+			messages[key] = &message{text: tmpl}
+			continue
+		}
+
+		var forms map[string]string
+		err = json.Unmarshal(value, &forms)
+		if err != nil {
+			return nil, fmt.Errorf("locale %s, message %s: %w", locale, key, err)
+		}
+		if _, ok := forms[pluralOther]; !ok {
+			return nil, fmt.Errorf("locale %s, message %s: plural form %q is required", locale, key, pluralOther)
+		}
+
+		msg := &message{forms: make(map[string]*template.Template, len(forms))}
+		for form, text := range forms {
+			tmpl, err := template.New(key + "." + form).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("locale %s, message %s: %w", locale, key, err)
+			}
+			msg.forms[form] = tmpl
+		}
+		messages[key] = msg
+	}
+
+	return messages, nil
+}
+
+// Locales returns the codes of all available locales.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.locales))
+	for locale := range c.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+// Has reports whether the locale is available.
+func (c *Catalog) Has(locale string) bool {
+	_, ok := c.locales[locale]
+	return ok
+}
+
+// Match picks the best available locale for a Telegram language code such
+// as "uk" or "en-US", falling back to the default locale.
+func (c *Catalog) Match(languageCode string) string {
+	languageCode = strings.ToLower(languageCode)
+	if c.Has(languageCode) {
+		return languageCode
+	}
+
+	base, _, _ := strings.Cut(languageCode, "-")
+	if c.Has(base) {
+		return base
+	}
+
+	return DefaultLocale
+}
+
+// T renders the message with the given key. Missing messages fall back to
+// the default locale and then to the key itself, so a missing translation
+// never breaks a reply.
+func (c *Catalog) T(locale, key string, data ...Data) string {
+	msg := c.lookup(locale, key)
+	if msg == nil {
+		return key
+	}
+
+	tmpl := msg.text
+	if tmpl == nil {
+		tmpl = msg.forms[pluralOther]
+	}
+
+	return render(tmpl, merge(data))
+}
+
+// Plural renders the plural form of the message matching count. The count
+// is available to the template as {{.Count}}.
+func (c *Catalog) Plural(locale, key string, count int, data ...Data) string {
+	msg := c.lookup(locale, key)
+	if msg == nil {
+		return key
+	}
+
+	values := merge(data)
+	values["Count"] = count
+
+	if msg.text != nil {
+		return render(msg.text, values)
+	}
+
+	tmpl, ok := msg.forms[pluralForm(locale, count)]
+	if !ok {
+		tmpl = msg.forms[pluralOther]
+	}
+
+	return render(tmpl, values)
+}
+
+func (c *Catalog) lookup(locale, key string) *message {
+	if messages, ok := c.locales[locale]; ok {
+		if msg, ok := messages[key]; ok {
+			return msg
+		}
+	}
+
+	return c.locales[DefaultLocale][key]
+}
+
+func merge(data []Data) Data {
+	values := make(Data)
+	for _, d := range data {
+		for k, v := range d {
+			values[k] = v
+		}
+	}
+
+	return values
+}
+
+func render(tmpl *template.Template, data Data) string {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return tmpl.Name()
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,35 @@
+package i18n
+
+const (
+	pluralOne   = "one"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+// pluralForm returns the CLDR plural category of count for the locale. Only
+// the rules of the shipped locales are implemented, everything else uses the
+// English rule.
+func pluralForm(locale string, count int) string {
+	if count < 0 {
+		count = -count
+	}
+
+	switch locale {
+	case "uk", "ru":
+		mod10, mod100 := count%10, count%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return pluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return pluralFew
+		default:
+			return pluralMany
+		}
+	default:
+		if count == 1 {
+			return pluralOne
+		}
+		return pluralOther
+	}
+}
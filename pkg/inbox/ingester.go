@@ -0,0 +1,155 @@
+package inbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/models"
+	"unicode/utf8"
+)
+
+var ErrUnknownRecipient = errors.New("unknown recipient")
+
+// Ingester adds the links of received mail to the reading list of the user
+// whose inbox address it was sent to. A message without links is added as
+// an article itself, with its text saved as the snapshot.
+type Ingester struct {
+	domain       string
+	usersDao     dao.Users
+	tasksDao     dao.Tasks
+	snapshotsDao dao.Snapshots
+	maxLinks     int
+}
+
+func NewIngester(domain string, usersDao dao.Users, tasksDao dao.Tasks, snapshotsDao dao.Snapshots, maxLinks int) *Ingester {
+	return &Ingester{
+		domain:       strings.ToLower(domain),
+		usersDao:     usersDao,
+		tasksDao:     tasksDao,
+		snapshotsDao: snapshotsDao,
+		maxLinks:     maxLinks,
+	}
+}
+
+// Lookup returns the ID of the user an address belongs to. Addresses are
+// "<token>@<domain>", anything after a "+" in the token is ignored.
+func (i *Ingester) Lookup(address string) (int64, error) {
+	local, domain, ok := strings.Cut(strings.ToLower(address), "@")
+	if !ok || domain != i.domain {
+		return 0, ErrUnknownRecipient
+	}
+	token, _, _ := strings.Cut(local, "+")
+
+	user, err := i.usersDao.GetUserByInboxToken(token)
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && user.Banned) {
+		return 0, ErrUnknownRecipient
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return user.Id, nil
+}
+
+// Deliver returns the number of added articles. Links that are already in
+// the list are skipped. Sending mail counts as activity of the user.
+func (i *Ingester) Deliver(userId int64, msg *Message) (int, error) {
+	err := i.usersDao.UpdateUserLastActive(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(msg.Links) == 0 {
+		return i.addMessage(userId, msg)
+	}
+
+	var added int
+	for _, link := range msg.Links {
+		if added == i.maxLinks {
+			break
+		}
+		// Longer links don't fit into the list.
+		if utf8.RuneCountInString(link) > models.MaxUrlLength {
+			continue
+		}
+
+		isNew, err := i.addTask(userId, link)
+		if err != nil {
+			return added, err
+		}
+		if isNew {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+func (i *Ingester) addMessage(userId int64, msg *Message) (int, error) {
+	if msg.Text == "" {
+		return 0, nil
+	}
+
+	messageUrl := msg.MessageURL()
+	if messageUrl == "" || utf8.RuneCountInString(messageUrl) > models.MaxUrlLength {
+		id := make([]byte, 16)
+		_, err := rand.Read(id)
+		if err != nil {
+			return 0, err
+		}
+		messageUrl = "mid:" + hex.EncodeToString(id) + "@" + i.domain
+	}
+
+	// Senders retry when they don't get an answer in time.
+	_, err := i.tasksDao.GetUsersTaskByUrl(userId, messageUrl)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, &errs.ErrNotFound{}) {
+		return 0, err
+	}
+
+	task, err := i.tasksDao.InsertTask(&models.Task{
+		UserId: userId,
+		Url:    messageUrl,
+		Status: models.TaskStatusNew,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = i.snapshotsDao.InsertSnapshot(&models.Snapshot{
+		TaskId: task.Id,
+		Title:  msg.Subject,
+		Text:   msg.Text,
+	})
+	if err != nil {
+		return 1, err
+	}
+
+	return 1, nil
+}
+
+func (i *Ingester) addTask(userId int64, link string) (bool, error) {
+	_, err := i.tasksDao.GetUsersTaskByUrl(userId, link)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, &errs.ErrNotFound{}) {
+		return false, err
+	}
+
+	_, err = i.tasksDao.InsertTask(&models.Task{
+		UserId: userId,
+		Url:    link,
+		Status: models.TaskStatusNew,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
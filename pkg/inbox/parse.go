@@ -0,0 +1,214 @@
+package inbox
+
+import (
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+	"tg_bot/pkg/snapshot"
+)
+
+// maxDepth bounds the nesting of multipart messages.
+const maxDepth = 5
+
+var textLink = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// skippedLinks are the parts of newsletter links that lead to the list
+// settings rather than to something to read.
+var skippedLinks = []string{"unsubscribe", "optout", "opt-out", "list-manage", "/preferences", "/email-settings"}
+
+// Message is what is kept of a received mail.
+type Message struct {
+	MessageId string
+	Subject   string
+	// Text is the plain text part, or the text of the HTML part if there is
+	// none.
+	Text string
+	// Links are the http(s) links to articles, in order of appearance.
+	Links []string
+}
+
+type parts struct {
+	plain []string
+	html  []string
+}
+
+// Parse reads a mail in RFC 5322 format. Bodies are expected to be UTF-8 or
+// ASCII, other charsets are kept as they are.
+func Parse(r io.Reader) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+
+	var p parts
+	err = p.walk(textproto.MIMEHeader(m.Header), m.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		MessageId: strings.Trim(m.Header.Get("Message-Id"), "<> "),
+		Subject:   strings.TrimSpace(subject),
+	}
+
+	seen := make(map[string]bool)
+	addLink := func(link string) {
+		link = strings.TrimRight(link, ".,;:!?")
+		if seen[link] || !articleLink(link) {
+			return
+		}
+		seen[link] = true
+		msg.Links = append(msg.Links, link)
+	}
+
+	for _, body := range p.html {
+		for _, link := range htmlLinks(body) {
+			addLink(link)
+		}
+	}
+	for _, body := range p.plain {
+		for _, link := range textLink.FindAllString(body, -1) {
+			addLink(link)
+		}
+	}
+
+	if len(p.plain) > 0 {
+		msg.Text = strings.TrimSpace(strings.Join(p.plain, "\n\n"))
+	} else if len(p.html) > 0 {
+		_, text, err := snapshot.Extract(strings.NewReader(strings.Join(p.html, "\n")))
+		if err != nil {
+			return nil, err
+		}
+		msg.Text = text
+	}
+
+	return msg, nil
+}
+
+func (p *parts) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("message is nested deeper than %d parts", maxDepth)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = p.walk(part.Header, part, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return nil
+	}
+	if disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition")); disposition == "attachment" {
+		return nil
+	}
+
+	content, err := io.ReadAll(decode(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	if mediaType == "text/html" {
+		p.html = append(p.html, string(content))
+	} else {
+		p.plain = append(p.plain, string(content))
+	}
+
+	return nil
+}
+
+// decode undoes the transfer encoding. multipart.Reader already decodes
+// quoted-printable parts and removes the header.
+func decode(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+func htmlLinks(body string) []string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					links = append(links, strings.TrimSpace(attr.Val))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+
+	return links
+}
+
+func articleLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	lower := strings.ToLower(link)
+	for _, skipped := range skippedLinks {
+		if strings.Contains(lower, skipped) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MessageURL is the mid: URL (RFC 2392) a message without links is stored
+// under.
+func (m *Message) MessageURL() string {
+	if m.MessageId == "" {
+		return ""
+	}
+
+	return "mid:" + url.PathEscape(m.MessageId)
+}
@@ -0,0 +1,381 @@
+package inbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"tg_bot/logger"
+	"tg_bot/pkg/metrics"
+	"time"
+)
+
+const (
+	commandTimeout = 5 * time.Minute
+	maxRecipients  = 10
+	maxConnections = 100
+)
+
+// Mailbox is where the server delivers accepted mail.
+type Mailbox interface {
+	// Lookup returns ErrUnknownRecipient for addresses nobody has.
+	Lookup(address string) (int64, error)
+	Deliver(userId int64, msg *Message) (int, error)
+}
+
+// Server is a receive-only SMTP server for the users' inbox addresses. It
+// never relays, mail to addresses other than the mailbox's is refused.
+// There is no TLS, put a proxy in front of it if that matters.
+type Server struct {
+	addr     string
+	hostname string
+	maxSize  int
+	mailbox  Mailbox
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func NewServer(addr string, maxSize int, mailbox Mailbox) *Server {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return &Server{
+		addr:     addr,
+		hostname: hostname,
+		maxSize:  maxSize,
+		mailbox:  mailbox,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe blocks until Shutdown is called.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	logger.Get().Info("SMTP server is listening", zap.String("addr", listener.Addr().String()))
+
+	slots := make(chan struct{}, maxConnections)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			_, _ = fmt.Fprintf(conn, "421 4.3.2 Too many connections, try again later\r\n")
+			_ = conn.Close()
+			continue
+		}
+
+		s.track(conn, true)
+		s.wg.Add(1)
+		go func() {
+			defer func() {
+				s.track(conn, false)
+				_ = conn.Close()
+				<-slots
+				s.wg.Done()
+			}()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for the open ones until
+// ctx is done, then closes them.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+	}
+
+	return err
+}
+
+func (s *Server) track(conn net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+type session struct {
+	server     *Server
+	conn       net.Conn
+	text       *textproto.Conn
+	from       string
+	recipients []int64
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	sess := &session{
+		server: s,
+		conn:   conn,
+		text:   textproto.NewConn(conn),
+	}
+
+	sess.reply(220, "%s ESMTP read_that_bot", s.hostname)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		if !sess.handle(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+// handle runs one command and returns false when the connection has to be
+// closed.
+func (sess *session) handle(verb, arg string) bool {
+	switch verb {
+	case "HELO":
+		sess.reset()
+		sess.reply(250, "%s", sess.server.hostname)
+	case "EHLO":
+		sess.reset()
+		_ = sess.text.PrintfLine("250-%s", sess.server.hostname)
+		_ = sess.text.PrintfLine("250-SIZE %d", sess.server.maxSize)
+		sess.reply(250, "8BITMIME")
+	case "MAIL":
+		sess.handleMail(arg)
+	case "RCPT":
+		sess.handleRcpt(arg)
+	case "DATA":
+		return sess.handleData()
+	case "RSET":
+		sess.reset()
+		sess.reply(250, "2.0.0 OK")
+	case "NOOP":
+		sess.reply(250, "2.0.0 OK")
+	case "VRFY":
+		sess.reply(252, "2.5.2 Cannot verify, send some mail")
+	case "QUIT":
+		sess.reply(221, "2.0.0 Bye")
+		return false
+	default:
+		sess.reply(502, "5.5.2 Command not implemented")
+	}
+
+	return true
+}
+
+func (sess *session) handleMail(arg string) {
+	if sess.from != "" {
+		sess.reply(503, "5.5.1 Sender already given")
+		return
+	}
+
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	for _, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, "SIZE") {
+			size, err := strconv.Atoi(value)
+			if err == nil && size > sess.server.maxSize {
+				sess.reply(552, "5.3.4 Message too big")
+				return
+			}
+		}
+	}
+
+	// The null sender of bounces is "<>".
+	if from == "" {
+		from = "<>"
+	}
+	sess.from = from
+	sess.reply(250, "2.1.0 OK")
+}
+
+func (sess *session) handleRcpt(arg string) {
+	if sess.from == "" {
+		sess.reply(503, "5.5.1 Need MAIL first")
+		return
+	}
+	if len(sess.recipients) >= maxRecipients {
+		sess.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || to == "" {
+		sess.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+
+	userId, err := sess.server.mailbox.Lookup(to)
+	if errors.Is(err, ErrUnknownRecipient) {
+		sess.reply(550, "5.1.1 No such user")
+		return
+	}
+	if err != nil {
+		logger.Get().Error("Could not look up mail recipient", zap.Error(err))
+		sess.reply(451, "4.3.0 Temporary failure, try again later")
+		return
+	}
+
+	// An address given twice gets the mail once.
+	for _, id := range sess.recipients {
+		if id == userId {
+			sess.reply(250, "2.1.5 OK")
+			return
+		}
+	}
+
+	sess.recipients = append(sess.recipients, userId)
+	sess.reply(250, "2.1.5 OK")
+}
+
+func (sess *session) handleData() bool {
+	if len(sess.recipients) == 0 {
+		sess.reply(503, "5.5.1 Need RCPT first")
+		return true
+	}
+
+	sess.reply(354, "Go ahead, end with <CRLF>.<CRLF>")
+
+	maxSize := int64(sess.server.maxSize)
+	body := sess.text.DotReader()
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return false
+	}
+	if int64(len(data)) > maxSize {
+		// The rest of the message has to be read before the reply.
+		_, err = io.Copy(io.Discard, body)
+		if err != nil {
+			return false
+		}
+		metrics.InboxMessages.WithLabelValues(metrics.ResultRejected).Inc()
+		sess.reset()
+		sess.reply(552, "5.3.4 Message too big")
+		return true
+	}
+
+	recipients := sess.recipients
+	sess.reset()
+
+	msg, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		metrics.InboxMessages.WithLabelValues(metrics.ResultRejected).Inc()
+		sess.reply(554, "5.6.0 Could not parse the message")
+		return true
+	}
+
+	// Recipients are delivered to one by one. Once the mail reached any of
+	// them it is accepted, a retry would add it again for the others.
+	delivered := 0
+	for _, userId := range recipients {
+		added, err := sess.server.mailbox.Deliver(userId, msg)
+		if err != nil {
+			logger.Get().Error("Could not deliver mail", zap.Int64("user_id", userId), zap.Error(err))
+			continue
+		}
+		delivered++
+		logger.Get().Info("Mail delivered", zap.Int64("user_id", userId), zap.Int("added", added))
+	}
+
+	if delivered == 0 {
+		metrics.InboxMessages.WithLabelValues(metrics.ResultError).Inc()
+		sess.reply(451, "4.3.0 Temporary failure, try again later")
+		return true
+	}
+
+	metrics.InboxMessages.WithLabelValues(metrics.ResultOk).Inc()
+	sess.reply(250, "2.0.0 OK")
+
+	return true
+}
+
+func (sess *session) reset() {
+	sess.from = ""
+	sess.recipients = nil
+}
+
+func (sess *session) reply(code int, format string, args ...any) {
+	_ = sess.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+// parsePath parses "FROM:<address> PARAM=value..." of MAIL and RCPT.
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+
+	fields := strings.Fields(strings.TrimSpace(arg[len(prefix):]))
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+
+	path := fields[0]
+	if !strings.HasPrefix(path, "<") || !strings.HasSuffix(path, ">") {
+		return "", nil, false
+	}
+
+	return path[1 : len(path)-1], fields[1:], true
+}
@@ -0,0 +1,244 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testMaxSize = 4096
+
+type fakeMailbox struct {
+	users map[string]int64
+	// failing users can't be delivered to.
+	failing map[int64]bool
+
+	mu        sync.Mutex
+	delivered map[int64][]*Message
+}
+
+func (f *fakeMailbox) Lookup(address string) (int64, error) {
+	userId, ok := f.users[strings.ToLower(address)]
+	if !ok {
+		return 0, ErrUnknownRecipient
+	}
+
+	return userId, nil
+}
+
+func (f *fakeMailbox) Deliver(userId int64, msg *Message) (int, error) {
+	if f.failing[userId] {
+		return 0, errors.New("database is down")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delivered[userId] = append(f.delivered[userId], msg)
+
+	return len(msg.Links), nil
+}
+
+func (f *fakeMailbox) messages(userId int64) []*Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delivered[userId]
+}
+
+func startServer(t *testing.T, mailbox *fakeMailbox) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	server := NewServer(listener.Addr().String(), testMaxSize, mailbox)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+
+	return listener.Addr().String()
+}
+
+func newMailbox() *fakeMailbox {
+	return &fakeMailbox{
+		users: map[string]int64{
+			"alice@in.example.com": 1,
+			"bob@in.example.com":   2,
+		},
+		failing:   map[int64]bool{},
+		delivered: map[int64][]*Message{},
+	}
+}
+
+func send(t *testing.T, client *smtp.Client, to []string, body string) error {
+	t.Helper()
+
+	err := client.Mail("sender@example.com")
+	if err != nil {
+		t.Fatalf("MAIL: %v", err)
+	}
+	for _, address := range to {
+		err = client.Rcpt(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func dial(t *testing.T, addr string) *smtp.Client {
+	t.Helper()
+
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func replyCode(err error) int {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code
+	}
+
+	return 0
+}
+
+func TestServerDelivers(t *testing.T) {
+	mailbox := newMailbox()
+	addr := startServer(t, mailbox)
+
+	body := "From: sender@example.com\r\n" +
+		"To: alice@in.example.com\r\n" +
+		"Subject: Weekend reading\r\n" +
+		"\r\n" +
+		"Have a look at https://example.com/article\r\n"
+	err := smtp.SendMail(addr, nil, "sender@example.com", []string{"Alice@in.example.com"}, []byte(body))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	messages := mailbox.messages(1)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Subject != "Weekend reading" {
+		t.Errorf("subject: got %q", msg.Subject)
+	}
+	if len(msg.Links) != 1 || msg.Links[0] != "https://example.com/article" {
+		t.Errorf("links: got %v", msg.Links)
+	}
+}
+
+func TestServerRefusesUnknownRecipient(t *testing.T) {
+	mailbox := newMailbox()
+	client := dial(t, startServer(t, mailbox))
+
+	err := send(t, client, []string{"nobody@in.example.com"}, "Subject: hi\r\n\r\nhi\r\n")
+	if code := replyCode(err); code != 550 {
+		t.Errorf("got %v, want 550", err)
+	}
+}
+
+func TestServerRefusesOversizeMessage(t *testing.T) {
+	mailbox := newMailbox()
+	client := dial(t, startServer(t, mailbox))
+
+	body := "Subject: big\r\n\r\n" + strings.Repeat("lorem ipsum dolor sit amet\r\n", testMaxSize/10)
+	err := send(t, client, []string{"alice@in.example.com"}, body)
+	if code := replyCode(err); code != 552 {
+		t.Fatalf("got %v, want 552", err)
+	}
+	if n := len(mailbox.messages(1)); n != 0 {
+		t.Errorf("delivered %d messages, want none", n)
+	}
+
+	// The session goes on after the refusal.
+	err = send(t, client, []string{"alice@in.example.com"}, "Subject: small\r\n\r\nhttps://example.com/a\r\n")
+	if err != nil {
+		t.Fatalf("send after refusal: %v", err)
+	}
+	if n := len(mailbox.messages(1)); n != 1 {
+		t.Errorf("delivered %d messages, want 1", n)
+	}
+}
+
+func TestServerUnstuffsDots(t *testing.T) {
+	mailbox := newMailbox()
+	client := dial(t, startServer(t, mailbox))
+
+	// The client doubles leading dots, the server has to remove them again.
+	body := "Subject: dots\r\n\r\n.starts with a dot\r\n..two dots\r\n.\r\nafter a lone dot\r\n"
+	err := send(t, client, []string{"alice@in.example.com"}, body)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	messages := mailbox.messages(1)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	text := messages[0].Text
+	for _, line := range []string{".starts with a dot", "..two dots", "after a lone dot"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("text %q is missing %q", text, line)
+		}
+	}
+	if strings.Contains(text, "...two dots") {
+		t.Errorf("text %q is still dot-stuffed", text)
+	}
+}
+
+func TestServerDeliversToEachRecipient(t *testing.T) {
+	mailbox := newMailbox()
+	mailbox.failing[2] = true
+	client := dial(t, startServer(t, mailbox))
+
+	to := []string{"alice@in.example.com", "ALICE@in.example.com", "bob@in.example.com"}
+	err := send(t, client, to, "Subject: hi\r\n\r\nhttps://example.com/a\r\n")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if n := len(mailbox.messages(1)); n != 1 {
+		t.Errorf("alice got %d messages, want 1", n)
+	}
+}
+
+func TestServerFailsIfNobodyGotIt(t *testing.T) {
+	mailbox := newMailbox()
+	mailbox.failing[1] = true
+	client := dial(t, startServer(t, mailbox))
+
+	err := send(t, client, []string{"alice@in.example.com"}, "Subject: hi\r\n\r\nhttps://example.com/a\r\n")
+	if code := replyCode(err); code != 451 {
+		t.Errorf("got %v, want 451", err)
+	}
+}
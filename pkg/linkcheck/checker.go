@@ -0,0 +1,89 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/netguard"
+	"time"
+)
+
+const userAgent = "read_that_bot link checker"
+
+// Result is the outcome of a link check. Status is empty if the check was
+// inconclusive, e.g. the server answered with 500 or timed out.
+type Result struct {
+	Status string
+	Code   int
+}
+
+type Checker struct {
+	client *http.Client
+}
+
+// NewChecker returns a checker that only connects to public addresses, so
+// links can't be used to probe the network the bot runs in.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		client: netguard.NewClient(timeout),
+	}
+}
+
+// Check asks for the headers of the page first and falls back to GET for
+// servers that don't support HEAD.
+func (c *Checker) Check(ctx context.Context, url string) Result {
+	code, err := c.request(ctx, http.MethodHead, url)
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented || code == http.StatusForbidden) {
+		code, err = c.request(ctx, http.MethodGet, url)
+	}
+
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return Result{Status: models.LinkStatusDead}
+		}
+		return Result{}
+	}
+
+	return Result{Status: classify(code), Code: code}
+}
+
+func (c *Checker) request(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Only the status matters, a bit of the body is read so the connection
+	// can be reused.
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+
+	return resp.StatusCode, nil
+}
+
+// classify treats only pages that are definitely gone as dead. Sites often
+// refuse bots with 401, 403 or 429 while the page is fine for people.
+func classify(code int) string {
+	switch {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return models.LinkStatusDead
+	case code < 400 || code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusTooManyRequests:
+		return models.LinkStatusAlive
+	default:
+		return ""
+	}
+}
+
+// WaybackURL points to the latest Wayback Machine snapshot of the page.
+func WaybackURL(url string) string {
+	return "https://web.archive.org/web/" + url
+}
@@ -0,0 +1,173 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// newTestChecker skips the public address guard, test servers listen on
+// loopback.
+func newTestChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   int
+		status string
+	}{
+		{"ok", http.StatusOK, models.LinkStatusAlive},
+		{"not found", http.StatusNotFound, models.LinkStatusDead},
+		{"gone", http.StatusGone, models.LinkStatusDead},
+		{"refused bot", http.StatusTooManyRequests, models.LinkStatusAlive},
+		{"server error", http.StatusInternalServerError, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.code)
+			}))
+			defer server.Close()
+
+			result := newTestChecker(time.Second).Check(context.Background(), server.URL)
+			if result.Status != test.status {
+				t.Errorf("status: got %q, want %q", result.Status, test.status)
+			}
+			if result.Status != "" && result.Code != test.code {
+				t.Errorf("code: got %d, want %d", result.Code, test.code)
+			}
+		})
+	}
+}
+
+func TestCheckFallsBackToGet(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result := newTestChecker(time.Second).Check(context.Background(), server.URL)
+	if result.Status != models.LinkStatusAlive || result.Code != http.StatusOK {
+		t.Errorf("got %+v, want alive with 200", result)
+	}
+	if fmt.Sprint(methods) != "[HEAD GET]" {
+		t.Errorf("got requests %v, want [HEAD GET]", methods)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	result := newTestChecker(50*time.Millisecond).Check(context.Background(), server.URL)
+	if result.Status != "" {
+		t.Errorf("got %q, want an inconclusive result", result.Status)
+	}
+}
+
+func TestCheckRefusesPrivateAddresses(t *testing.T) {
+	var called atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	result := NewChecker(time.Second).Check(context.Background(), server.URL)
+	if result.Status != "" {
+		t.Errorf("got %q, want an inconclusive result", result.Status)
+	}
+	if called.Load() {
+		t.Errorf("the request reached the server")
+	}
+}
+
+type fakeTasks struct {
+	dao.Tasks
+	tasks []*models.Task
+
+	mu       sync.Mutex
+	statuses map[int64]string
+}
+
+func (f *fakeTasks) GetTasksToCheck(time.Time, uint64) ([]*models.Task, error) {
+	return f.tasks, nil
+}
+
+func (f *fakeTasks) UpdateTaskLinkStatus(taskId int64, status string, code int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[taskId] = status
+
+	return nil
+}
+
+func TestJobConcurrency(t *testing.T) {
+	const concurrency = 3
+
+	var running, maxRunning atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	tasksDao := &fakeTasks{statuses: map[int64]string{}}
+	for i := 1; i <= 12; i++ {
+		path := "/ok"
+		if i%4 == 0 {
+			path = "/gone"
+		}
+		tasksDao.tasks = append(tasksDao.tasks, &models.Task{Id: int64(i), Url: server.URL + path})
+	}
+
+	job := NewJob(tasksDao, newTestChecker(time.Second), time.Hour, concurrency, 100)
+	dead, err := job.Run(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if dead != 3 {
+		t.Errorf("dead: got %d, want 3", dead)
+	}
+	if len(tasksDao.statuses) != len(tasksDao.tasks) {
+		t.Errorf("checked %d tasks, want %d", len(tasksDao.statuses), len(tasksDao.tasks))
+	}
+	if max := maxRunning.Load(); max > concurrency {
+		t.Errorf("%d checks ran at once, want at most %d", max, concurrency)
+	}
+}
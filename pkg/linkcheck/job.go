@@ -0,0 +1,69 @@
+package linkcheck
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+// Job checks the links of NEW tasks that weren't checked for a while.
+type Job struct {
+	tasksDao    dao.Tasks
+	checker     *Checker
+	interval    time.Duration
+	concurrency int
+	batchSize   uint64
+}
+
+func NewJob(tasksDao dao.Tasks, checker *Checker, interval time.Duration, concurrency int, batchSize uint64) *Job {
+	return &Job{
+		tasksDao:    tasksDao,
+		checker:     checker,
+		interval:    interval,
+		concurrency: concurrency,
+		batchSize:   batchSize,
+	}
+}
+
+// Run checks one batch of links and returns how many were found dead.
+func (j *Job) Run(ctx context.Context, now time.Time) (int, error) {
+	tasks, err := j.tasksDao.GetTasksToCheck(now.Add(-j.interval), j.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	dead := 0
+	sem := make(chan struct{}, j.concurrency)
+	for _, task := range tasks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(task *models.Task) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result := j.checker.Check(ctx, task.Url)
+			err := j.tasksDao.UpdateTaskLinkStatus(task.Id, result.Status, result.Code)
+			if err != nil {
+				logger.Get().Error("Could not save link status", zap.Int64("task_id", task.Id), zap.Error(err))
+				return
+			}
+
+			if result.Status == models.LinkStatusDead {
+				mu.Lock()
+				dead++
+				mu.Unlock()
+			}
+		}(task)
+	}
+	wg.Wait()
+
+	return dead, nil
+}
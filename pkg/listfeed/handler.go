@@ -0,0 +1,151 @@
+package listfeed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/errs"
+	"tg_bot/pkg/metrics"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+const (
+	Prefix = "/feed/"
+
+	FormatAtom = "atom"
+	FormatJSON = "json"
+
+	// maxNew is how many unread articles, the latest first, are in a feed.
+	maxNew = 100
+	// Readers poll often, a few minutes of delay don't matter for a list.
+	cacheControl = "private, max-age=300"
+	// activeStep keeps every poll of a reader from writing the user's last
+	// activity, retention only needs it to the day.
+	activeStep = time.Hour
+)
+
+// URL returns the address of a reading list feed in the format.
+func URL(publicUrl, token, format string) string {
+	return strings.TrimRight(publicUrl, "/") + Prefix + token + "." + format
+}
+
+// Handler serves every user's articles being read and unread ones as
+// feeds, so they can be read in feed readers:
+//
+//	GET /feed/{token}.atom  Atom
+//	GET /feed/{token}.json  JSON Feed 1.1
+//
+// The token in the URL is the only authentication, users get it with /rss.
+// Only its hash is stored. Unknown tokens get 404 like any other unknown
+// path.
+type Handler struct {
+	usersDao  dao.Users
+	tasksDao  dao.Tasks
+	publicUrl string
+}
+
+func NewHandler(usersDao dao.Users, tasksDao dao.Tasks, publicUrl string) *Handler {
+	return &Handler{
+		usersDao:  usersDao,
+		tasksDao:  tasksDao,
+		publicUrl: publicUrl,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, format, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, Prefix), ".")
+	if format != FormatAtom && format != FormatJSON {
+		http.NotFound(w, r)
+		return
+	}
+
+	result := metrics.ResultOk
+	defer func() {
+		metrics.ListFeedRequests.WithLabelValues(format, result).Inc()
+	}()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		result = metrics.ResultRejected
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := h.usersDao.GetUserByFeedTokenHash(HashToken(token))
+	if errors.Is(err, &errs.ErrNotFound{}) || (err == nil && (token == "" || user.Banned)) {
+		result = metrics.ResultRejected
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		result = metrics.ResultError
+		writeInternalError(w, err)
+		return
+	}
+
+	// Reading the list in a feed reader counts as activity.
+	if time.Since(user.LastActiveAt) > activeStep {
+		err = h.usersDao.UpdateUserLastActive(user.Id)
+		if err != nil {
+			logger.Get().Error("Could not update last activity", zap.Int64("user_id", user.Id), zap.Error(err))
+		}
+	}
+
+	tasks, err := h.feedTasks(user)
+	if err != nil {
+		result = metrics.ResultError
+		writeInternalError(w, err)
+		return
+	}
+
+	feedUrl := URL(h.publicUrl, token, format)
+	var body []byte
+	if format == FormatAtom {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		body, err = renderAtom(user, feedUrl, tasks)
+	} else {
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+		body, err = renderJSON(feedUrl, tasks)
+	}
+	if err != nil {
+		result = metrics.ResultError
+		writeInternalError(w, err)
+		return
+	}
+
+	// The list can shrink without any article changing, so the ETag is
+	// taken from the body rather than a date.
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// feedTasks returns the articles being read followed by the latest unread
+// ones.
+func (h *Handler) feedTasks(user *models.User) ([]*models.Task, error) {
+	inProgress, err := h.tasksDao.GetUsersTasksByStatus(user.Id, models.TaskStatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	backlog, err := h.tasksDao.GetUsersRecentTasksByStatus(user.Id, models.TaskStatusNew, maxNew)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(inProgress, backlog...), nil
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	refErr := errs.NewErrWithRef(err)
+	logger.Get().Error("Reading list feed request failed", zap.String("error_ref", refErr.Ref), zap.Error(err))
+	http.Error(w, "internal error, reference "+refErr.Ref, http.StatusInternalServerError)
+}
@@ -0,0 +1,167 @@
+package listfeed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/ratings"
+	"time"
+)
+
+const (
+	feedTitle  = "Reading list"
+	feedAuthor = "read_that_bot"
+
+	jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type jsonFeed struct {
+	Version string     `json:"version"`
+	Title   string     `json:"title"`
+	FeedUrl string     `json:"feed_url"`
+	Items   []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	Id            string    `json:"id"`
+	Url           string    `json:"url"`
+	Title         string    `json:"title"`
+	ContentText   string    `json:"content_text"`
+	DatePublished time.Time `json:"date_published"`
+	DateModified  time.Time `json:"date_modified"`
+	Tags          []string  `json:"tags,omitempty"`
+}
+
+// renderAtom builds the feed. Its ID comes from the user rather than the
+// token, so a reader sees the same feed at a new URL from /rss.
+func renderAtom(user *models.User, feedUrl string, tasks []*models.Task) ([]byte, error) {
+	feed := atomFeed{
+		Id:      fmt.Sprintf("urn:read-that-bot:list:%d", user.Id),
+		Title:   feedTitle,
+		Updated: formatTime(lastUpdate(user, tasks)),
+		Link:    atomLink{Rel: "self", Href: feedUrl},
+		Author:  atomAuthor{Name: feedAuthor},
+	}
+	for _, task := range tasks {
+		entry := atomEntry{
+			Id:        fmt.Sprintf("urn:read-that-bot:task:%d", task.Id),
+			Title:     entryTitle(task),
+			Link:      atomLink{Rel: "alternate", Href: task.Url},
+			Published: formatTime(task.CreatedAt),
+			Updated:   formatTime(task.UpdatedAt),
+			Summary:   entryText(task),
+		}
+		for _, tag := range task.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+func renderJSON(feedUrl string, tasks []*models.Task) ([]byte, error) {
+	feed := jsonFeed{
+		Version: jsonFeedVersion,
+		Title:   feedTitle,
+		FeedUrl: feedUrl,
+		Items:   make([]jsonItem, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		feed.Items = append(feed.Items, jsonItem{
+			Id:            strconv.FormatInt(task.Id, 10),
+			Url:           task.Url,
+			Title:         entryTitle(task),
+			ContentText:   entryText(task),
+			DatePublished: task.CreatedAt.UTC(),
+			DateModified:  task.UpdatedAt.UTC(),
+			Tags:          task.Tags,
+		})
+	}
+
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+// entryTitle is the site of the article, the title of the page isn't
+// known. Articles being read are marked, readers list them by date.
+func entryTitle(task *models.Task) string {
+	title := ratings.Domain(task.Url)
+	if title == "" {
+		title = task.Url
+	}
+	if task.Status == models.TaskStatusInProgress {
+		title = "▶ " + title
+	}
+
+	return title
+}
+
+func entryText(task *models.Task) string {
+	text := task.Url
+	if len(task.Tags) > 0 {
+		text += "\n#" + strings.Join(task.Tags, " #")
+	}
+
+	return text
+}
+
+// lastUpdate is when the newest article in the feed changed. An empty feed
+// takes the time of the user, so the body and its ETag stay the same.
+func lastUpdate(user *models.User, tasks []*models.Task) time.Time {
+	var last time.Time
+	for _, task := range tasks {
+		if task.UpdatedAt.After(last) {
+			last = task.UpdatedAt
+		}
+	}
+	if last.IsZero() {
+		return user.UpdatedAt
+	}
+
+	return last
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,29 @@
+package listfeed
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const tokenBytes = 16
+
+// NewToken returns a random feed token and the hash it is stored as.
+func NewToken() (string, string, error) {
+	raw := make([]byte, tokenBytes)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := hex.EncodeToString(raw)
+
+	return token, HashToken(token), nil
+}
+
+// HashToken is a plain SHA-256 like the hash of API tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
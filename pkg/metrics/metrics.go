@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+const namespace = "tg_bot"
+
+var (
+	UpdatesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_processed_total",
+		Help:      "Number of processed bot commands by command and result.",
+	}, []string{"command", "result"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent handling a bot command.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time spent in DAO methods.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"dao", "method"})
+
+	Reminders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminders_total",
+		Help:      "Number of reminder and digest messages by result.",
+	}, []string{"result"})
+
+	BacklogTasks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backlog_tasks",
+		Help:      "Number of tasks of all users by status.",
+	}, []string{"status"})
+
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Number of REST API requests by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	InboxMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inbox_messages_total",
+		Help:      "Number of mails received by the SMTP server by result.",
+	}, []string{"result"})
+
+	FeedPolls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feed_polls_total",
+		Help:      "Number of subscribed feed checks by result.",
+	}, []string{"result"})
+
+	ListFeedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "list_feed_requests_total",
+		Help:      "Number of reading list feed requests by format and result.",
+	}, []string{"format", "result"})
+
+	LastPoll = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_poll_timestamp_seconds",
+		Help:      "Unix time of the last successful getUpdates call.",
+	})
+)
+
+const (
+	ResultOk       = "ok"
+	ResultRejected = "rejected"
+	ResultError    = "error"
+
+	FeedNotModified = "not_modified"
+
+	ReminderSent   = "sent"
+	ReminderFailed = "failed"
+)
+
+// ObserveQuery records the duration of a DAO method, meant to be deferred
+// at the top of the method:
+//
+//	defer metrics.ObserveQuery("tasks", "InsertTask", time.Now())
+func ObserveQuery(dao, method string, start time.Time) {
+	DBQueryDuration.WithLabelValues(dao, method).Observe(time.Since(start).Seconds())
+}
+
+func ObserveReminder(err error) {
+	if err != nil {
+		Reminders.WithLabelValues(ReminderFailed).Inc()
+		return
+	}
+	Reminders.WithLabelValues(ReminderSent).Inc()
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Feed is an RSS or Atom feed a user subscribed to. New entries of the feed
+// are added to the user's list.
+type Feed struct {
+	Id     int64
+	UserId int64
+	Url    string
+	Title  string
+	// ETag and LastModified come from the last response and are sent back,
+	// so an unchanged feed isn't downloaded again.
+	ETag         string
+	LastModified string
+	// SeenEntries are the keys of the entries in the feed at the last check.
+	SeenEntries []string
+	// Failures counts the checks that failed in a row.
+	Failures int
+	// CheckedAt is zero if the feed was never checked.
+	CheckedAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Note is a thought about an article, e.g. the takeaway asked for after
+// /done.
+type Note struct {
+	Id        int64
+	TaskId    int64
+	Text      string
+	CreatedAt time.Time
+}
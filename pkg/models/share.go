@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Share is an article offered through a deep link or to a single user.
+// Everyone who opens a link gets the article added to their list, an
+// article offered to a user is added once they accept it.
+type Share struct {
+	Token  string
+	UserId int64
+	// RecipientId is the user the article was offered to, zero for links.
+	RecipientId int64
+	// FromName is how the sharer is shown to the recipients.
+	FromName  string
+	Url       string
+	Tags      []string
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Snapshot is the readable text of an article saved when it was added, so
+// it can still be read after the page is gone.
+type Snapshot struct {
+	TaskId    int64
+	Title     string
+	Text      string
+	CreatedAt time.Time
+}
@@ -6,8 +6,29 @@ const (
 	TaskStatusNew        = "NEW"
 	TaskStatusInProgress = "IN_PROGRESS"
 	TaskStatusDone       = "DONE"
+	TaskStatusArchived   = "ARCHIVED"
 )
 
+// Ratings go from MinRating to MaxRating, zero means not rated.
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// MaxUrlLength is the size of the url columns of tasks, shares and feeds,
+// in characters.
+const MaxUrlLength = 500
+
+// Link statuses are set by the dead link checker. Links that were never
+// checked, or whose check was inconclusive, have an empty status.
+const (
+	LinkStatusAlive = "ALIVE"
+	LinkStatusDead  = "DEAD"
+)
+
+// TaskStatuses lists every status, e.g. for reports.
+var TaskStatuses = []string{TaskStatusNew, TaskStatusInProgress, TaskStatusDone, TaskStatusArchived}
+
 type TaskStatus string
 
 func (s TaskStatus) String() string {
@@ -15,10 +36,19 @@ func (s TaskStatus) String() string {
 }
 
 type Task struct {
-	Id        int64
-	UserId    int64
-	Url       string
-	Status    string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Id     int64
+	UserId int64
+	Url    string
+	Status string
+	// Tags are lowercase and without the leading #.
+	Tags       []string
+	LinkStatus string
+	// LinkStatusCode is the HTTP status of the last link check, zero if the
+	// server couldn't be reached.
+	LinkStatusCode int
+	// LinkCheckedAt is zero if the link was never checked.
+	LinkCheckedAt time.Time
+	Rating        int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Token describes a user's API token, the token itself isn't stored.
+type Token struct {
+	UserId    int64
+	CreatedAt time.Time
+	// LastUsedAt is zero if the token was never used.
+	LastUsedAt time.Time
+}
@@ -5,7 +5,25 @@ import "time"
 type User struct {
 	Id         int64
 	ExternalId string
-	ChatId     int64
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// Username is the lowercased Telegram username without "@", empty if
+	// the user has none.
+	Username         string
+	ChatId           int64
+	ReminderSchedule string
+	ReminderCount    int
+	DigestEnabled    bool
+	Language         string
+	Banned           bool
+	LastActiveAt     time.Time
+	// ReferredBy is the ID of the user whose invite link brought this one,
+	// zero if none.
+	ReferredBy int64
+	// InboxToken is the local part of the user's inbox mail address, empty
+	// until they ask for one.
+	InboxToken string
+	// FeedTokenHash is the SHA-256 of the secret part of the URLs of the
+	// user's reading list feeds, empty while they are off.
+	FeedTokenHash string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
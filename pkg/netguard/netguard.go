@@ -0,0 +1,66 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is returned when dialing an address that isn't on the public
+// internet.
+var ErrNotPublic = errors.New("address is not public")
+
+// NewClient returns an HTTP client that only connects to public addresses,
+// for fetching URLs users give the bot. The check is done when dialing, so
+// it covers redirects and host names resolving to internal addresses. No
+// proxy is used, it would hide the address being connected to.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Control,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// Control is a net.Dialer hook that refuses to connect to loopback,
+// private, link-local, multicast and unspecified addresses.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotPublic, address)
+	}
+
+	if !IsPublic(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrNotPublic, address)
+	}
+
+	return nil
+}
+
+// IsPublic reports whether addr may be connected to. IPv4 addresses mapped
+// to IPv6 are checked as IPv4.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
@@ -0,0 +1,59 @@
+package netguard
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fc00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"224.0.0.1:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:10.0.0.1]:80", false},
+		{"not an address", false},
+	}
+
+	for _, test := range tests {
+		err := Control("tcp", test.address, nil)
+		if test.allowed && err != nil {
+			t.Errorf("%s: got %v, want allowed", test.address, err)
+		}
+		if !test.allowed && !errors.Is(err, ErrNotPublic) {
+			t.Errorf("%s: got %v, want ErrNotPublic", test.address, err)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrNotPublic) {
+		t.Errorf("got %v, want ErrNotPublic", err)
+	}
+	if called {
+		t.Errorf("the request reached the server")
+	}
+}
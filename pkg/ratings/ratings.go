@@ -0,0 +1,166 @@
+package ratings
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"tg_bot/pkg/models"
+)
+
+const (
+	// neutral is the rating that neither raises nor lowers the chances of
+	// similar articles.
+	neutral = 3.0
+	// minWeight keeps articles from disliked sources possible, just rare.
+	minWeight = 0.2
+)
+
+// Score is the average rating of the articles from a domain or with a tag.
+type Score struct {
+	Name    string
+	Average float64
+	Count   int
+}
+
+// Preferences sum up a user's ratings per domain and per tag.
+type Preferences struct {
+	domains map[string]*Score
+	tags    map[string]*Score
+}
+
+// NewPreferences sums up the ratings of the given tasks, unrated ones are
+// skipped.
+func NewPreferences(rated []*models.Task) *Preferences {
+	p := &Preferences{
+		domains: make(map[string]*Score),
+		tags:    make(map[string]*Score),
+	}
+	for _, task := range rated {
+		if task.Rating < models.MinRating {
+			continue
+		}
+		add(p.domains, Domain(task.Url), task.Rating)
+		for _, tag := range task.Tags {
+			add(p.tags, tag, task.Rating)
+		}
+	}
+
+	return p
+}
+
+func add(scores map[string]*Score, name string, rating int) {
+	if name == "" {
+		return
+	}
+
+	s, ok := scores[name]
+	if !ok {
+		s = &Score{Name: name}
+		scores[name] = s
+	}
+	s.Average = (s.Average*float64(s.Count) + float64(rating)) / float64(s.Count+1)
+	s.Count++
+}
+
+// Weight is how likely the task should be picked compared to an unknown
+// one, which has weight 1. Well rated domains and tags raise it, badly
+// rated ones lower it.
+func (p *Preferences) Weight(task *models.Task) float64 {
+	weight := 1.0
+	if s, ok := p.domains[Domain(task.Url)]; ok {
+		weight += bias(s.Average)
+	}
+
+	var tagBias float64
+	var tagCount int
+	for _, tag := range task.Tags {
+		if s, ok := p.tags[tag]; ok {
+			tagBias += bias(s.Average)
+			tagCount++
+		}
+	}
+	if tagCount > 0 {
+		weight += tagBias / float64(tagCount)
+	}
+
+	if weight < minWeight {
+		return minWeight
+	}
+
+	return weight
+}
+
+// bias maps an average rating to [-1, 1].
+func bias(average float64) float64 {
+	return (average - neutral) / (models.MaxRating - neutral)
+}
+
+// Pick chooses a task at random, weighted by the preferences.
+func (p *Preferences) Pick(tasks []*models.Task) *models.Task {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	weights := make([]float64, len(tasks))
+	var total float64
+	for i, task := range tasks {
+		weights[i] = p.Weight(task)
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return tasks[i]
+		}
+	}
+
+	return tasks[len(tasks)-1]
+}
+
+// TopDomains returns the best rated domains with at least minCount ratings.
+func (p *Preferences) TopDomains(limit, minCount int) []Score {
+	return top(p.domains, limit, minCount)
+}
+
+// TopTags returns the best rated tags with at least minCount ratings.
+func (p *Preferences) TopTags(limit, minCount int) []Score {
+	return top(p.tags, limit, minCount)
+}
+
+func top(scores map[string]*Score, limit, minCount int) []Score {
+	var list []Score
+	for _, s := range scores {
+		if s.Count >= minCount {
+			list = append(list, *s)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Average != list[j].Average {
+			return list[i].Average > list[j].Average
+		}
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	return list
+}
+
+// Domain returns the host of the URL without "www.", or an empty string if
+// it isn't a URL.
+func Domain(rawUrl string) string {
+	u, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,60 @@
+package reminders
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"sort"
+	"strings"
+)
+
+const DefaultSchedule = "0 17 * * *"
+
+const MaxCount = 10
+
+// Presets are the named schedules users can pick with /schedule instead of
+// writing a cron expression by hand. All times are UTC.
+var Presets = map[string]string{
+	"daily":       DefaultSchedule,
+	"weekdays":    "0 17 * * 1-5",
+	"mwf":         "0 17 * * 1,3,5",
+	"twice_daily": "0 9,17 * * *",
+	"weekly":      "0 17 * * 0",
+}
+
+// ParseSchedule resolves a preset name or validates a standard 5-field cron
+// expression and returns the cron expression to store.
+func ParseSchedule(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if preset, ok := Presets[strings.ToLower(input)]; ok {
+		return preset, nil
+	}
+
+	_, err := cron.ParseStandard(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid schedule %q: %w", input, err)
+	}
+
+	return input, nil
+}
+
+// PresetName returns the preset name for the cron expression, or the
+// expression itself if it isn't one of the presets.
+func PresetName(schedule string) string {
+	for name, preset := range Presets {
+		if preset == schedule {
+			return name
+		}
+	}
+
+	return schedule
+}
+
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,120 @@
+package reminders
+
+import (
+	"github.com/go-co-op/gocron"
+	"go.uber.org/zap"
+	"sync"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"tg_bot/pkg/models"
+	"time"
+)
+
+type Sender interface {
+	SendUserReminder(user *models.User) error
+}
+
+type userJob struct {
+	job      *gocron.Job
+	schedule string
+}
+
+// Scheduler keeps one cron job per user in sync with the schedules stored in
+// the database. Sync is run periodically, so schedule changes are picked up
+// without restarting the bot.
+type Scheduler struct {
+	cron     *gocron.Scheduler
+	usersDao dao.Users
+	sender   Sender
+
+	mu   sync.Mutex
+	jobs map[int64]*userJob
+}
+
+func NewScheduler(cron *gocron.Scheduler, usersDao dao.Users, sender Sender) *Scheduler {
+	return &Scheduler{
+		cron:     cron,
+		usersDao: usersDao,
+		sender:   sender,
+		jobs:     make(map[int64]*userJob),
+	}
+}
+
+func (s *Scheduler) Start(syncInterval time.Duration) error {
+	_, err := s.cron.Every(syncInterval).Do(func() {
+		err := s.Sync()
+		if err != nil {
+			logger.Get().Error("Failed to sync reminder schedules", zap.Error(err))
+		}
+	})
+
+	return err
+}
+
+func (s *Scheduler) Sync() error {
+	users, err := s.usersDao.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int64]bool, len(users))
+	for _, user := range users {
+		seen[user.Id] = true
+
+		schedule := user.ReminderSchedule
+		if schedule == "" {
+			schedule = DefaultSchedule
+		}
+
+		existing, ok := s.jobs[user.Id]
+		if ok && existing.schedule == schedule {
+			continue
+		}
+		if ok {
+			s.cron.RemoveByReference(existing.job)
+			delete(s.jobs, user.Id)
+		}
+
+		userId := user.Id
+		job, err := s.cron.Cron(schedule).Do(func() {
+			s.remind(userId)
+		})
+		if err != nil {
+			logger.Get().Error("Could not schedule reminder",
+				zap.Int64("user_id", userId),
+				zap.String("schedule", schedule),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		s.jobs[user.Id] = &userJob{job: job, schedule: schedule}
+	}
+
+	for userId, existing := range s.jobs {
+		if !seen[userId] {
+			s.cron.RemoveByReference(existing.job)
+			delete(s.jobs, userId)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) remind(userId int64) {
+	// The user is reloaded so that the reminder uses the current count
+	// even if it changed since the last sync.
+	user, err := s.usersDao.GetUserById(userId)
+	if err != nil {
+		logger.Get().Error("Could not get user for reminder", zap.Int64("user_id", userId), zap.Error(err))
+		return
+	}
+
+	err = s.sender.SendUserReminder(user)
+	if err != nil {
+		logger.Get().Error("Failed to send reminder", zap.Int64("user_id", userId), zap.Error(err))
+	}
+}
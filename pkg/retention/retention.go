@@ -0,0 +1,59 @@
+package retention
+
+import (
+	"go.uber.org/zap"
+	"tg_bot/logger"
+	"tg_bot/pkg/dao"
+	"time"
+)
+
+// Forget deletes the user and everything they saved.
+func Forget(usersDao dao.Users, tasksDao dao.Tasks, userId int64) error {
+	err := tasksDao.DeleteUsersTasks(userId)
+	if err != nil {
+		return err
+	}
+
+	return usersDao.DeleteUser(userId)
+}
+
+// Sweeper deletes users who haven't sent a command for longer than
+// inactiveAfter. Banned users are kept, otherwise the sweep would lift the
+// ban.
+type Sweeper struct {
+	usersDao      dao.Users
+	tasksDao      dao.Tasks
+	inactiveAfter time.Duration
+}
+
+func NewSweeper(usersDao dao.Users, tasksDao dao.Tasks, inactiveAfter time.Duration) *Sweeper {
+	return &Sweeper{
+		usersDao:      usersDao,
+		tasksDao:      tasksDao,
+		inactiveAfter: inactiveAfter,
+	}
+}
+
+// Sweep returns the number of deleted users.
+func (s *Sweeper) Sweep(now time.Time) (int, error) {
+	users, err := s.usersDao.GetUsersInactiveSince(now.Add(-s.inactiveAfter))
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, user := range users {
+		if user.Banned {
+			continue
+		}
+
+		err = Forget(s.usersDao, s.tasksDao, user.Id)
+		if err != nil {
+			logger.Get().Error("Could not delete inactive user", zap.Int64("user_id", user.Id), zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
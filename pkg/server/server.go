@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"net/http"
+	"tg_bot/logger"
+	"time"
+)
+
+const pingTimeout = 2 * time.Second
+
+// Server exposes metrics and health checks over HTTP.
+type Server struct {
+	http *http.Server
+	mux  *http.ServeMux
+	db   *sql.DB
+
+	lastPoll   func() time.Time
+	maxPollAge time.Duration
+	startedAt  time.Time
+}
+
+// NewServer creates a server listening on addr. The bot is considered ready
+// while the database answers pings and lastPoll is not older than
+// maxPollAge. A nil lastPoll skips the polling check.
+func NewServer(addr string, db *sql.DB, lastPoll func() time.Time, maxPollAge time.Duration) *Server {
+	mux := http.NewServeMux()
+	s := &Server{
+		http: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:        mux,
+		db:         db,
+		lastPoll:   lastPoll,
+		maxPollAge: maxPollAge,
+		startedAt:  time.Now(),
+	}
+
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+
+	return s
+}
+
+// Handle registers an additional handler, e.g. the webhook endpoint. It must
+// be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) Start() {
+	go func() {
+		logger.Get().Info("HTTP server is listening", zap.String("addr", s.http.Addr))
+
+		err := s.http.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			logger.Get().Error("HTTP server failed", zap.Error(err))
+		}
+	}()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+// handleHealthz is a liveness check, it only tells that the process serves
+// requests.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		logger.Get().Warn("Readiness check failed: database", zap.Error(err))
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if s.lastPoll == nil {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+		return
+	}
+
+	lastPoll := s.lastPoll()
+	if lastPoll.IsZero() {
+		// The first long poll may legitimately take a while to return.
+		if time.Since(s.startedAt) > s.maxPollAge {
+			http.Error(w, "no successful poll yet", http.StatusServiceUnavailable)
+			return
+		}
+	} else if time.Since(lastPoll) > s.maxPollAge {
+		logger.Get().Warn("Readiness check failed: polling", zap.Time("last_poll", lastPoll))
+		http.Error(w, "last poll is too old", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
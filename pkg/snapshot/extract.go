@@ -0,0 +1,133 @@
+package snapshot
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"strings"
+)
+
+// skipped elements never contain article text.
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+}
+
+// blocks end a paragraph of the extracted text.
+var blocks = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Section:    true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+	atom.Pre:        true,
+	atom.Tr:         true,
+	atom.Br:         true,
+}
+
+// Extract pulls the title and the readable text out of an HTML page. The
+// text is taken from <article> or <main> if the page has one, from <body>
+// otherwise, leaving out navigation, scripts and similar clutter.
+func Extract(r io.Reader) (title string, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	title = strings.TrimSpace(textOf(find(doc, atom.Title)))
+	if h1 := find(doc, atom.H1); title == "" && h1 != nil {
+		title = strings.TrimSpace(textOf(h1))
+	}
+
+	root := find(doc, atom.Article)
+	if root == nil {
+		root = find(doc, atom.Main)
+	}
+	if root == nil {
+		root = find(doc, atom.Body)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		p := strings.Join(strings.Fields(current.String()), " ")
+		if p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skipped[n.DataAtom] {
+			return
+		}
+		if n.Type == html.TextNode {
+			current.WriteString(n.Data)
+			current.WriteString(" ")
+			return
+		}
+
+		isBlock := n.Type == html.ElementNode && blocks[n.DataAtom]
+		if isBlock {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if isBlock {
+			flush()
+		}
+	}
+	walk(root)
+	flush()
+
+	return title, strings.Join(paragraphs, "\n\n"), nil
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func textOf(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textOf(c))
+	}
+
+	return b.String()
+}
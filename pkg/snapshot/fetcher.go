@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"tg_bot/pkg/models"
+	"tg_bot/pkg/netguard"
+	"time"
+)
+
+const userAgent = "read_that_bot snapshot fetcher"
+
+var ErrNotHTML = errors.New("page is not HTML")
+
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// NewFetcher returns a fetcher that only connects to public addresses, so
+// added links can't reach services in the network the bot runs in.
+func NewFetcher(timeout time.Duration, maxSize int64) *Fetcher {
+	return &Fetcher{
+		client:  netguard.NewClient(timeout),
+		maxSize: maxSize,
+	}
+}
+
+// Fetch downloads the page and extracts its text. Pages larger than
+// maxSize are cut off, which at worst loses the end of the text.
+func (f *Fetcher) Fetch(ctx context.Context, task *models.Task) (*models.Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, task.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	title, text, err := Extract(io.LimitReader(resp.Body, f.maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Snapshot{
+		TaskId: task.Id,
+		Title:  title,
+		Text:   text,
+	}, nil
+}